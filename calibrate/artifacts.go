@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"log"
+	"math"
+	"proj3/gridsearch"
+	"proj3/regression"
+	"strings"
+)
+
+// Encodings of the typed artifact -artifacts writes next to every results file
+var artifactExtensions = map[string]string{"proto": ".pb", "gob": ".gob"}
+
+// A finished task as a typed artifact, the Result message of calibrate.proto. Parameters and ConvergedEpoch are nil
+// when there is no winner or it never converged
+type resultArtifact struct {
+	Task string
+	Hyperparameters Hyperparameters
+	Parameters *regression.Parameters
+	MSE float64
+	ConvergedEpoch *int
+	TrainSeconds float64
+	TaskSeconds float64
+	DataFingerprint string
+	Baseline regression.Parameters
+	BaselineMSE float64
+}
+
+// Builds the artifact of a finished task from its winner
+func newResultArtifact(hyperParams Hyperparameters, parameters regression.Parameters, mse float64, stats gridsearch.Stats,
+	taskSeconds float64, opts searchOptions) resultArtifact {
+	artifact := resultArtifact{Task: hyperParams.Outpath, Hyperparameters: hyperParams, MSE: mse, TrainSeconds: stats.Seconds,
+		TaskSeconds: taskSeconds, DataFingerprint: opts.fingerprint, Baseline: opts.baseline, BaselineMSE: opts.baselineMSE}
+	if hyperParams.Optimizer != nil {
+		artifact.Parameters = &parameters
+	}
+	if stats.ConvergedEpoch >= 0 {
+		artifact.ConvergedEpoch = &stats.ConvergedEpoch
+	}
+	return artifact
+}
+
+// Writes the artifact next to its results file in encoding, eg results.pb for results.csv and proto
+func writeArtifact(artifact resultArtifact, encoding string, policy writePolicy) {
+	var content []byte
+	switch encoding {
+	case "proto":
+		content = artifact.encodeProto()
+	case "gob":
+		var buffer bytes.Buffer
+		if err := gob.NewEncoder(&buffer).Encode(artifact); err != nil {
+			log.Fatal("Error: cannot encode the artifact of ", artifact.Task, ": ", err)
+		}
+		content = buffer.Bytes()
+	}
+	extension := outpathExtension(artifact.Task)
+	policy.write(strings.TrimSuffix(artifact.Task, extension) + artifactExtensions[encoding], content)
+}
+
+// Encodes the artifact as a Result message
+func (a resultArtifact) encodeProto() []byte {
+	var m protoMessage
+	m.str(1, a.Task)
+	m.message(2, encodeHyperparametersProto(a.Hyperparameters))
+	if a.Parameters != nil {
+		m.message(3, encodeParametersProto(*a.Parameters))
+	}
+	m.double(4, a.MSE)
+	if a.ConvergedEpoch != nil {
+		m.tag(5, protoVarint)
+		m.varint(uint64(*a.ConvergedEpoch))
+	}
+	m.double(6, a.TrainSeconds)
+	m.double(7, a.TaskSeconds)
+	m.str(8, a.DataFingerprint)
+	m.message(9, encodeParametersProto(a.Baseline))
+	m.double(10, a.BaselineMSE)
+	return m.Bytes()
+}
+
+func encodeParametersProto(parameters regression.Parameters) *protoMessage {
+	var m protoMessage
+	m.double(1, parameters.Mu)
+	m.double(2, parameters.Beta)
+	return &m
+}
+
+func encodeHyperparametersProto(h Hyperparameters) *protoMessage {
+	var m protoMessage
+	m.str(1, h.Outpath)
+	m.doubles(2, h.Alpha)
+	m.doubles(3, h.NumEpochs)
+	m.doubles(4, h.Lambda)
+	m.doubles(5, h.MiniBatchSize)
+	m.strs(6, h.Optimizer)
+	m.doubles(7, h.Momentum)
+	m.doubles(8, h.History)
+	m.doubles(9, h.Warmup)
+	m.strs(10, h.Schedule)
+	m.doubles(11, h.MinAlpha)
+	m.doubles(12, h.Cycle)
+	m.integer(13, h.Seed)
+	m.strs(14, h.Loss)
+	m.doubles(15, h.Epsilon)
+	m.strs(16, h.Link)
+	m.doubles(17, h.Threshold)
+	m.doubles(18, h.Tolerance)
+	m.doubles(19, h.Factor)
+	m.doubles(20, h.Patience)
+	m.strs(22, h.Target)
+	m.doubles(23, h.BoxCox)
+	m.boolean(24, h.NoIntercept)
+	m.str(25, h.NonNegative)
+	return &m
+}
+
+// Wire types of the protobuf encoding
+const (
+	protoVarint = 0
+	protoFixed64 = 1
+	protoBytes = 2
+)
+
+// A protobuf message encoded field by field. Scalars equal to their proto3 default are left out, as protoc's encoders
+// do, and repeated floats and doubles are packed
+type protoMessage struct {
+	bytes.Buffer
+}
+
+func (m *protoMessage) tag(field int, wireType int) {
+	m.varint(uint64(field << 3 | wireType))
+}
+
+func (m *protoMessage) varint(value uint64) {
+	var scratch [binary.MaxVarintLen64]byte
+	m.Write(scratch[:binary.PutUvarint(scratch[:], value)])
+}
+
+func (m *protoMessage) fixed64(value uint64) {
+	var scratch [8]byte
+	binary.LittleEndian.PutUint64(scratch[:], value)
+	m.Write(scratch[:])
+}
+
+func (m *protoMessage) double(field int, value float64) {
+	if value == 0 && !math.Signbit(value) {
+		return
+	}
+	m.tag(field, protoFixed64)
+	m.fixed64(math.Float64bits(value))
+}
+
+func (m *protoMessage) integer(field int, value int64) {
+	if value != 0 {
+		m.tag(field, protoVarint)
+		m.varint(uint64(value)) //negative int64s take ten bytes, two's complement
+	}
+}
+
+func (m *protoMessage) boolean(field int, value bool) {
+	if value {
+		m.tag(field, protoVarint)
+		m.varint(1)
+	}
+}
+
+func (m *protoMessage) str(field int, value string) {
+	if value != "" {
+		m.tag(field, protoBytes)
+		m.varint(uint64(len(value)))
+		m.WriteString(value)
+	}
+}
+
+func (m *protoMessage) strs(field int, values []string) {
+	for _, value := range values {
+		m.tag(field, protoBytes)
+		m.varint(uint64(len(value)))
+		m.WriteString(value)
+	}
+}
+
+func (m *protoMessage) floats(field int, values []float32) {
+	if len(values) == 0 {
+		return
+	}
+	m.tag(field, protoBytes)
+	m.varint(uint64(4 * len(values)))
+	var scratch [4]byte
+	for _, value := range values {
+		binary.LittleEndian.PutUint32(scratch[:], math.Float32bits(value))
+		m.Write(scratch[:])
+	}
+}
+
+func (m *protoMessage) doubles(field int, values []float64) {
+	if len(values) == 0 {
+		return
+	}
+	m.tag(field, protoBytes)
+	m.varint(uint64(8 * len(values)))
+	for _, value := range values {
+		m.fixed64(math.Float64bits(value))
+	}
+}
+
+func (m *protoMessage) message(field int, sub *protoMessage) {
+	m.tag(field, protoBytes)
+	m.varint(uint64(sub.Len()))
+	m.Write(sub.Bytes())
+}
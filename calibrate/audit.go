@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// One line of the audit log. Fields that do not apply to an event are left out
+type auditEvent struct {
+	Time string `json:"time"`
+	Run string `json:"run"`
+	Event string `json:"event"`
+	Task int `json:"task,omitempty"` // 1-based position of the task in the input
+	Outpath string `json:"outpath,omitempty"`
+	Message string `json:"message,omitempty"`
+	Permutations *int `json:"permutations,omitempty"`
+	Input *jsonInput `json:"input,omitempty"`
+	Result *taskSummary `json:"result,omitempty"`
+}
+
+// An append-only JSON lines log of what a run did, so operators can reconstruct it afterwards: the run starting with
+// its arguments, every task received with its validation outcome, every task's start, and its finish with the summary
+// of its result, and the run finishing. Errors and warnings logged by the run are recorded too, so a run stopped by
+// an invalid task shows why. Every event is stamped with the time and an id of the run, as several runs can append
+// to one file. Goroutines record concurrently, so writes are serialized by a lock. A nil audit log records nothing
+type auditLog struct {
+	lock sync.Mutex
+	file *os.File
+	run string
+}
+
+// Opens the audit log at path for appending, creating it if needed, records the start of the run and starts
+// recording the run's errors and warnings
+func newAuditLog(path string) *auditLog {
+	file, err := os.OpenFile(path, os.O_WRONLY | os.O_CREATE | os.O_APPEND, 0644)
+	if err != nil {
+		log.Fatal("Error: cannot open audit log ", path, ": ", err)
+	}
+	a := &auditLog{file: file, run: randomHex(8)}
+	a.record(auditEvent{Event: "run started", Message: commandLine()})
+	log.SetOutput(auditedLogOutput{os.Stderr, a})
+	return a
+}
+
+// Returns the arguments the process was started with
+func commandLine() string {
+	encoded, _ := json.Marshal(os.Args)
+	return string(encoded)
+}
+
+// Appends one event to the log
+func (a *auditLog) record(event auditEvent) {
+	if a == nil {
+		return
+	}
+	event.Time = time.Now().UTC().Format(time.RFC3339Nano)
+	event.Run = a.run
+	line, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.file.Write(append(line, '\n'))
+}
+
+// Records a task received from the input, before it is validated
+func (a *auditLog) received(task int, input jsonInput) {
+	a.record(auditEvent{Event: "task received", Task: task, Outpath: input.Outpath, Input: &input})
+}
+
+// Records a task that passed validation, with the size of its grid
+func (a *auditLog) validated(task int, outpath string, permutations int) {
+	a.record(auditEvent{Event: "task validated", Task: task, Outpath: outpath, Permutations: &permutations})
+}
+
+// Records the start of a task's search
+func (a *auditLog) started(outpath string, permutations int) {
+	a.record(auditEvent{Event: "task started", Outpath: outpath, Permutations: &permutations})
+}
+
+// Records a task left out of the search, and why
+func (a *auditLog) skipped(outpath string, reason string) {
+	a.record(auditEvent{Event: "task skipped", Outpath: outpath, Message: reason})
+}
+
+// Records a task that failed before it was searched, and why
+func (a *auditLog) failed(outpath string, reason string) {
+	a.record(auditEvent{Event: "task failed", Outpath: outpath, Message: reason})
+}
+
+// Records a finished task with the summary of its result
+func (a *auditLog) finished(summary taskSummary) {
+	a.record(auditEvent{Event: "task finished", Outpath: summary.Task, Result: &summary})
+}
+
+// Records the end of the run and closes the log
+func (a *auditLog) Close() {
+	if a == nil {
+		return
+	}
+	a.record(auditEvent{Event: "run finished"})
+	log.SetOutput(os.Stderr)
+	a.file.Close()
+}
+
+// The log output of an audited run: every line still goes to its output, and errors and warnings are also recorded
+// into the audit log
+type auditedLogOutput struct {
+	output io.Writer
+	audit *auditLog
+}
+
+func (o auditedLogOutput) Write(line []byte) (int, error) {
+	if bytes.Contains(line, []byte("Error: ")) {
+		o.audit.record(auditEvent{Event: "error", Message: string(bytes.TrimSpace(line))})
+	} else if bytes.Contains(line, []byte("Warning: ")) {
+		o.audit.record(auditEvent{Event: "warning", Message: string(bytes.TrimSpace(line))})
+	}
+	return o.output.Write(line)
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"os"
+	"proj3/data"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// A search strategy the bench subcommand compares. It searches the permutations of a task on up to threads
+// goroutines, calling evaluate, which is safe for concurrent use, on each one it trains; seed seeds any randomness
+type benchStrategy func(permutations []Hyperparameters, threads int, seed int64, evaluate func(Hyperparameters))
+
+// Strategies by their -strategies name. A new strategy, eg successive halving, only needs an entry here to be benched
+// against the others on the same grid
+var benchStrategies = map[string]benchStrategy{
+	"sequential": func(permutations []Hyperparameters, threads int, seed int64, evaluate func(Hyperparameters)) {
+		for _, permutation := range permutations {
+			evaluate(permutation)
+		}
+	},
+	"parallel": benchParallel,
+	"random": func(permutations []Hyperparameters, threads int, seed int64, evaluate func(Hyperparameters)) {
+		shuffled := append([]Hyperparameters(nil), permutations...)
+		rand.New(rand.NewSource(seed)).Shuffle(len(shuffled), func(i, j int) { shuffled[i], shuffled[j] = shuffled[j], shuffled[i] })
+		benchParallel(shuffled, threads, seed, evaluate)
+	},
+}
+
+// Searches the permutations in parallel as the search does: each of threads goroutines trains one contiguous slice
+func benchParallel(permutations []Hyperparameters, threads int, seed int64, evaluate func(Hyperparameters)) {
+	size := (len(permutations) + threads - 1) / threads
+	var group sync.WaitGroup
+	for start := 0; start < len(permutations); start += size {
+		end := start + size
+		if end > len(permutations) {
+			end = len(permutations)
+		}
+		group.Add(1)
+		go func(slice []Hyperparameters) {
+			defer group.Done()
+			for _, permutation := range slice {
+				evaluate(permutation)
+			}
+		}(permutations[start:end])
+	}
+	group.Wait()
+}
+
+// A point of a time-to-best-MSE curve: the best MSE once evaluated permutations had finished, seconds into the run
+type benchPoint struct {
+	seconds float64
+	evaluated int
+	bestMSE float64
+}
+
+// Entry point of the bench subcommand: calibrate bench -i="filename.csv" -tasks="tasks.json" -t=4 -o="curves.csv"
+// Searches the grid of every task once with each strategy, sequential, parallel and random (the grid in a random
+// order, in parallel), and prints how long each took to finish and to reach its best MSE, and to come within 1% of
+// it. The time-to-best-MSE curves, a point per improvement, are written to -o, so strategies can be chosen on
+// evidence of how soon they find a good model rather than only how soon they finish. No results files are written
+func bench(args []string) {
+	flags := flag.NewFlagSet("bench", flag.ExitOnError)
+	inpath := flags.String("i", "", "filepath of the input data csv file")
+	tasksPath := flags.String("tasks", "", "file of the JSON tasks whose grids are benched")
+	threads := flags.String("t", "auto", "goroutines of the parallel strategies, or auto for one per CPU")
+	strategiesFlag := flags.String("strategies", "sequential,parallel,random", "comma separated strategies to compare")
+	seed := flags.Int64("seed", 1, "seed of the random strategy's order")
+	curvesPath := flags.String("o", "", "csv file the time-to-best-MSE curves are written to, none if empty")
+	missing := flags.String("missing", "drop", "handling of missing values: drop or mean")
+	categorical := flags.String("categorical", "", "comma separated indexes of categorical csv columns")
+	scale := flags.String("scale", "minmax", "scaling of each independent column before training: minmax or standard")
+	flags.Parse(args)
+	if *inpath == "" || *tasksPath == "" {
+		fmt.Println("Usage: calibrate bench -i=\"filename.csv\" -tasks=\"tasks.json\" -t=4 -strategies=sequential,parallel,random -o=\"curves.csv\"")
+		os.Exit(0)
+	}
+	strategies := parseColumnNames(*strategiesFlag)
+	for _, name := range strategies {
+		if benchStrategies[name] == nil {
+			log.Fatal("Error: unknown bench strategy ", name)
+		}
+	}
+	numThreads := parseThreads(*threads)
+
+	trainingData := data.LoadTrainingData(*inpath, data.LoadOptions{Missing: *missing, Categorical: parseColumnIndexes(*categorical)})
+	tasksFile, err := os.Open(*tasksPath)
+	if err != nil {
+		log.Fatal("Error: cannot open the tasks file ", *tasksPath, ": ", err)
+	}
+	tasks := readJSONInputTasks(tasksFile)
+	tasksFile.Close()
+
+	prepared := newPreparedData()
+	curves := [][]string{{"task", "strategy", "seconds", "evaluated", "bestMSE"}}
+	for _, task := range tasks {
+		permutations := createArrayParamPermutations(task)
+		fmt.Printf("Task %s: %d permutations on %d threads\n", task.Outpath, len(permutations), numThreads)
+		fmt.Printf("%-12s %12s %12s %12s %16s\n", "strategy", "seconds", "toBest", "toWithin1%", "bestMSE")
+		for _, name := range strategies {
+			curve := benchStrategyRun(benchStrategies[name], permutations, numThreads, *seed,
+				prepared.forTask(trainingData, searchOptions{scale: *scale}, task))
+			last := benchPoint{bestMSE: math.NaN()}
+			if len(curve) > 0 {
+				last = curve[len(curve) - 1]
+			}
+			toBest, toWithin := math.NaN(), math.NaN()
+			for _, point := range curve {
+				if math.IsNaN(toWithin) && point.bestMSE <= last.bestMSE + 0.01 * math.Abs(last.bestMSE) {
+					toWithin = point.seconds
+				}
+				if math.IsNaN(toBest) && point.bestMSE == last.bestMSE {
+					toBest = point.seconds
+				}
+				curves = append(curves, []string{task.Outpath, name, strconv.FormatFloat(point.seconds, 'f', 6, 64),
+					strconv.Itoa(point.evaluated), strconv.FormatFloat(point.bestMSE, 'g', -1, 64)})
+			}
+			fmt.Printf("%-12s %12.4f %12.4f %12.4f %16.6g\n", name, last.seconds, toBest, toWithin, last.bestMSE)
+		}
+	}
+	if *curvesPath != "" {
+		writePolicy{}.writeCSV(*curvesPath, curves)
+		fmt.Println("Wrote the time-to-best-MSE curves into filepath:", *curvesPath)
+	}
+}
+
+// Runs a strategy on a task's permutations and returns its time-to-best-MSE curve: a point whenever the best MSE so
+// far improved, and a last one when the search finished
+func benchStrategyRun(strategy benchStrategy, permutations []Hyperparameters, threads int, seed int64,
+	prepared *preparedTask) []benchPoint {
+	var lock sync.Mutex
+	var curve []benchPoint
+	best, evaluated := math.Inf(1), 0
+	start := time.Now()
+	strategy(permutations, threads, seed, func(permutation Hyperparameters) {
+		_, mse, _ := evaluateHyperparams(prepared, permutation)
+		lock.Lock()
+		defer lock.Unlock()
+		evaluated++
+		if mse < best {
+			best = mse
+			curve = append(curve, benchPoint{time.Since(start).Seconds(), evaluated, best})
+		}
+	})
+	if math.IsInf(best, 1) { //no permutation reached a finite MSE
+		best = math.NaN()
+	}
+	return append(curve, benchPoint{time.Since(start).Seconds(), evaluated, best})
+}
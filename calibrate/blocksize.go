@@ -0,0 +1,81 @@
+package main
+
+import (
+	"log"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Largest block -b=auto grows to, so the last blocks of a run still spread over the readers
+const maxAutoBlockSize = 256
+
+// Shares of a block's time past which -b=auto doubles or halves the block size, and of idle time below which it
+// halves it
+const (
+	blockGrowIdle = 0.1
+	blockShrinkIdle = 0.02
+)
+
+// The number of JSON tasks a reader takes from stdin at a time. A fixed -b keeps its size for the whole run. With
+// -b=auto it starts at one task and adjusts after every block to how the reader and its worker spent the block's
+// time. While the reader waits for the stdin lock its worker idles, and readers queueing for the lock between short
+// blocks of fast tasks double the size. While it decodes the block the worker idles too, but if that is a slow
+// producer writing tasks, a bigger block would only hold back the tasks that did arrive, so the size halves. Once
+// both are negligible it halves as well, so a few readers are not left training big blocks while the others have run
+// out of tasks. Readers share it, so it is behind a lock
+type blockSizer struct {
+	lock sync.Mutex
+	size int
+	auto bool
+}
+
+// Parses -b: a positive number of tasks, or auto
+func newBlockSizer(value string) *blockSizer {
+	if value == "auto" {
+		return &blockSizer{size: 1, auto: true}
+	}
+	size, err := strconv.Atoi(value)
+	if err != nil || size < 1 {
+		log.Fatal("Error: -b must be a positive number of tasks or auto, not ", value)
+	}
+	return &blockSizer{size: size}
+}
+
+// Returns the size of the next block to read
+func (b *blockSizer) next() int {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.size
+}
+
+// Records a block whose reader waited lockWait for the stdin lock and decode reading it, and whose worker trained it
+// for busy, adjusting an adaptive size. Returns the size of the next block
+func (b *blockSizer) observe(lockWait time.Duration, decode time.Duration, busy time.Duration) int {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	total := float64(lockWait + decode + busy)
+	if !b.auto || total <= 0 {
+		return b.size
+	}
+	switch {
+	case float64(decode) / total > blockGrowIdle, float64(lockWait + decode) / total < blockShrinkIdle:
+		if b.size > 1 {
+			b.size /= 2
+		}
+	case float64(lockWait) / total > blockGrowIdle:
+		b.size *= 2
+		if b.size > maxAutoBlockSize {
+			b.size = maxAutoBlockSize
+		}
+	}
+	return b.size
+}
+
+// Describes the block size for the input args line
+func (b *blockSizer) String() string {
+	if b.auto {
+		return "auto"
+	}
+	return strconv.Itoa(b.size)
+}
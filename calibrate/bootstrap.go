@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"proj3/data"
+	"proj3/regression"
+	"strings"
+	"sync"
+)
+
+// Refits the winning hyperparameters on bootstrap resamples of the data the winner was fit on. Resamples are split
+// into contiguous chunks over numThreads goroutines, the same way a worker splits permutations, and resample i is
+// drawn from seed + i so the distribution is reproducible for a given -seed whatever the thread count
+func bootstrapWinner(fitData data.InputData, hyperParams Hyperparameters, replicates int, numThreads int, seed int64,
+	scale string) []regression.Parameters {
+	if numThreads < 1 {
+		numThreads = 1
+	}
+	results := make([]regression.Parameters, replicates)
+	chunkSize := (replicates + numThreads - 1) / numThreads
+	var group sync.WaitGroup
+	for start := 0; start < replicates; start += chunkSize {
+		end := start + chunkSize
+		if end > replicates {
+			end = replicates
+		}
+		group.Add(1)
+		go func(start int, end int) {
+			for i := start; i < end; i++ { //each goroutine fills its own indexes of results, so no lock is needed
+				results[i] = refitOnFullData(data.Bootstrap(fitData, seed + int64(i) + 1), hyperParams, scale)
+			}
+			group.Done()
+		}(start, end)
+	}
+	group.Wait()
+	return results
+}
+
+// Returns the 2.5th, 50th and 97.5th percentiles of beta and of mu over bootstrap replicates, formatted as results
+// columns in that order
+func bootstrapPercentiles(replicates []regression.Parameters) []string {
+	betas, mus := make([]float64, len(replicates)), make([]float64, len(replicates))
+	for i, parameters := range replicates {
+		betas[i], mus[i] = parameters.Beta, parameters.Mu
+	}
+	columns := make([]string, 0, 6)
+	for _, values := range [][]float64{betas, mus} {
+		for _, q := range []float64{0.025, 0.5, 0.975} {
+			columns = append(columns, fmt.Sprintf("%f", regression.Quantile(values, q)))
+		}
+	}
+	return columns
+}
+
+// Writes every bootstrap replicate's beta and mu next to the results file, eg results.csv to results_bootstrap.csv,
+// so the full coefficient distribution can be inspected or plotted
+func writeBootstrap(outpath string, replicates []regression.Parameters, policy writePolicy) {
+	extension := outpathExtension(outpath)
+	rows := [][]string{{"replicate", "beta", "mu"}}
+	for i, parameters := range replicates {
+		rows = append(rows, []string{fmt.Sprint(i), fmt.Sprintf("%f", parameters.Beta), fmt.Sprintf("%f", parameters.Mu)})
+	}
+	policy.writeCSV(strings.TrimSuffix(outpath, extension) + "_bootstrap" + extension, rows)
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Page of the dashboard, compiled into the binary so it serves without any files next to it
+//go:embed dashboard.html
+var dashboardPage []byte
+
+// Permutations kept per task for the dashboard's scatter plot. Past it every other point is dropped and only every
+// second new one kept, and so on, so a huge grid still plots evenly across its search in bounded memory
+const dashboardMaxPoints = 5000
+
+// A web dashboard of the search, served on -dashboard: live progress and the best MSE so far of every task, a scatter
+// of MSE against any hyperparameter, and links to download the results files of finished tasks. It is fed as a
+// permutation sink and a notifier, and search goroutines call it concurrently, so its state is behind a lock
+type dashboard struct {
+	lock sync.Mutex
+	started time.Time
+	tasks map[string]*dashboardTask
+	order []string //outpaths in the order their tasks started
+}
+
+// State of one task on the dashboard
+type dashboardTask struct {
+	Task string `json:"task"`
+	Status string `json:"status"` // running, finished or failed
+	Done int `json:"done"`
+	Total int `json:"total"`
+	BestMSE *float64 `json:"bestMSE"`
+	BestHyperparameters map[string]string `json:"bestHyperparameters"`
+	Started time.Time `json:"started"`
+	Reason string `json:"reason,omitempty"` // why a failed task has no winner
+	Download bool `json:"download"` // its results file can be downloaded
+	points []dashboardPoint
+	stride int //every stride-th permutation is kept as a point
+}
+
+// One evaluated permutation on the scatter plot
+type dashboardPoint struct {
+	Hyperparameters map[string]string `json:"hyperparameters"`
+	MSE *float64 `json:"mse"`
+}
+
+// Starts serving the dashboard on addr, eg ":8090", in the background
+func serveDashboard(addr string) *dashboard {
+	d := &dashboard{started: time.Now(), tasks: make(map[string]*dashboardTask)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(dashboardPage)
+	})
+	mux.HandleFunc("/api/state", d.serveState)
+	mux.HandleFunc("/api/points", d.servePoints)
+	mux.HandleFunc("/results", d.serveResults)
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		log.Fatal("Error: cannot listen for the dashboard on ", addr, ": ", err)
+	}
+	go http.Serve(listener, mux)
+	return d
+}
+
+// Records the start of a task's search over total permutations. A nil dashboard records nothing
+func (d *dashboard) start(outpath string, total int) {
+	if d == nil {
+		return
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.task(outpath).Total = total
+}
+
+// Returns the state of a task, adding it if it is new. Called with the lock held
+func (d *dashboard) task(outpath string) *dashboardTask {
+	task, ok := d.tasks[outpath]
+	if !ok {
+		task = &dashboardTask{Task: outpath, Status: "running", Started: time.Now(), stride: 1}
+		d.tasks[outpath] = task
+		d.order = append(d.order, outpath)
+	}
+	return task
+}
+
+func (d *dashboard) permutation(result configResult) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	task := d.task(result.Task)
+	task.Done++
+	if result.MSE != nil && (task.BestMSE == nil || *result.MSE < *task.BestMSE) {
+		task.BestMSE, task.BestHyperparameters = result.MSE, result.Hyperparameters
+	}
+	if task.Done % task.stride != 0 {
+		return
+	}
+	task.points = append(task.points, dashboardPoint{Hyperparameters: result.Hyperparameters, MSE: result.MSE})
+	if len(task.points) >= dashboardMaxPoints {
+		kept := task.points[:0]
+		for i := 1; i < len(task.points); i += 2 {
+			kept = append(kept, task.points[i])
+		}
+		task.points, task.stride = kept, task.stride * 2
+	}
+}
+
+func (d *dashboard) taskFinished(summary taskSummary) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	task := d.task(summary.Task)
+	task.Status = "finished"
+	if summary.MSE != nil {
+		task.BestMSE, task.BestHyperparameters = summary.MSE, summary.Hyperparameters
+	}
+	_, err := os.Stat(summary.Task)
+	task.Download = err == nil
+	return nil
+}
+
+func (d *dashboard) taskFailed(summary taskSummary, reason string) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	task := d.task(summary.Task)
+	task.Status, task.Reason = "failed", reason
+	_, err := os.Stat(summary.Task)
+	task.Download = err == nil
+	return nil
+}
+
+// Answers the state of every task in the order they started, and the seconds since the search started
+func (d *dashboard) serveState(w http.ResponseWriter, r *http.Request) {
+	d.lock.Lock()
+	state := struct {
+		ElapsedSeconds float64 `json:"elapsedSeconds"`
+		Tasks []dashboardTask `json:"tasks"`
+	}{time.Since(d.started).Seconds(), make([]dashboardTask, 0, len(d.order))}
+	for _, outpath := range d.order {
+		state.Tasks = append(state.Tasks, *d.tasks[outpath])
+	}
+	content, err := json.Marshal(state)
+	d.lock.Unlock()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(content)
+}
+
+// Answers the points of the scatter plot of ?task=outpath, and the hyperparameters they vary in, sorted
+func (d *dashboard) servePoints(w http.ResponseWriter, r *http.Request) {
+	d.lock.Lock()
+	task, ok := d.tasks[r.URL.Query().Get("task")]
+	points := []dashboardPoint{}
+	if ok {
+		points = append(points, task.points...)
+	}
+	d.lock.Unlock()
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	values := make(map[string]map[string]bool)
+	for _, point := range points {
+		for name, value := range point.Hyperparameters {
+			if values[name] == nil {
+				values[name] = make(map[string]bool)
+			}
+			values[name][value] = true
+		}
+	}
+	varied := make([]string, 0, len(values))
+	for name, seen := range values {
+		if len(seen) > 1 {
+			varied = append(varied, name)
+		}
+	}
+	sort.Strings(varied)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		Hyperparameters []string `json:"hyperparameters"`
+		Points []dashboardPoint `json:"points"`
+	}{varied, points})
+}
+
+// Sends the results file of ?task=outpath as a download. Only the outpaths of the search's tasks are served, so the
+// dashboard cannot be used to read other files
+func (d *dashboard) serveResults(w http.ResponseWriter, r *http.Request) {
+	outpath := r.URL.Query().Get("task")
+	d.lock.Lock()
+	task, ok := d.tasks[outpath]
+	download := ok && task.Download
+	d.lock.Unlock()
+	if !download {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Disposition", "attachment; filename=\"" + filepath.Base(outpath) + "\"")
+	http.ServeFile(w, r, outpath)
+}
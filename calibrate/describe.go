@@ -0,0 +1,48 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"proj3/data"
+	"proj3/regression"
+)
+
+// Entry point of the describe subcommand: calibrate describe -i="filename.csv"
+// Prints summary statistics of a training data file, the same statistics the grid search relies on for normalization
+func describe(args []string) {
+	flags := flag.NewFlagSet("describe", flag.ExitOnError)
+	inpath := flags.String("i", "", "filepath of the input data csv file")
+	categorical := flags.String("categorical", "", "comma separated indexes of categorical csv columns")
+	flags.Parse(args)
+	if *inpath == "" {
+		fmt.Println("Usage: calibrate describe -i=\"filename.csv\"")
+		os.Exit(0)
+	}
+
+	rawData := data.LoadTrainingData(*inpath, data.LoadOptions{Missing: "keep", Categorical: parseColumnIndexes(*categorical)})
+	stats := rawData.Stats //of the observed values, as missing ones are kept
+	fmt.Println("File:", *inpath)
+	fmt.Println("Rows:", len(rawData.X))
+	fmt.Printf("%-8s %8s %14s %14s %14s %14s\n", "column", "missing", "min", "max", "mean", "std")
+	describeColumn("x", stats.X, len(rawData.X))
+	for i, feature := range rawData.Features {
+		describeColumn(rawData.FeatureNames[i], stats.Features[i], len(feature))
+	}
+	describeColumn("y", stats.Y, len(rawData.Y))
+
+	completeData := data.DropMissing(rawData)
+	if len(completeData.X) > 1 {
+		fmt.Printf("Correlation(x, y): %.6f over %d complete rows\n", regression.Correlation(completeData.X, completeData.Y), len(completeData.X))
+	}
+}
+
+// Prints one row of the describe table from the statistics of the observed values of a column of rows rows
+func describeColumn(name string, summary data.ColumnStats, rows int) {
+	missing := rows - summary.Count
+	if summary.Count == 0 {
+		fmt.Printf("%-8s %8d %14s %14s %14s %14s\n", name, missing, "NA", "NA", "NA", "NA")
+		return
+	}
+	fmt.Printf("%-8s %8d %14.6f %14.6f %14.6f %14.6f\n", name, missing, summary.Min, summary.Max, summary.Mean, summary.StdDev())
+}
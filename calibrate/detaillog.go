@@ -0,0 +1,160 @@
+package main
+
+import (
+	"compress/gzip"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"path/filepath"
+	"proj3/data"
+	"proj3/gridsearch"
+	"proj3/regression"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A csv log of every permutation a search evaluates, not just the winners, gzip compressed if its path ends in .gz, or
+// an Arrow (Feather v2) file of the same columns if its path ends in .arrow or .feather, which loads into pandas or
+// polars at once however big the grid. Worker goroutines send their rows down a channel to a single writer goroutine, which batches them and writes a batch
+// once it holds batchRows rows or every flushInterval, whichever comes first, rather than thrashing the disk with a
+// write per row. Each Arrow batch is a record batch
+type detailedLog struct {
+	file *data.AtomicFile
+	compressed bool //the csv is gzip compressed, as its path ends in .gz
+	arrow *data.ArrowFile
+	rows chan []string
+	finished chan error
+	batchRows int
+	flushInterval time.Duration
+}
+
+// Creates the detailed log file, writes its header and starts its writer goroutine
+func newDetailedLog(path string, batchRows int, flushInterval time.Duration) *detailedLog {
+	if batchRows < 1 {
+		batchRows = 1
+	}
+	if flushInterval <= 0 {
+		flushInterval = time.Second
+	}
+	l := &detailedLog{rows: make(chan []string, batchRows), finished: make(chan error), batchRows: batchRows,
+		flushInterval: flushInterval}
+	header := append(append([]string{"outpath"}, hyperparamHeader...), "beta", "mu", "mse", "convergedEpoch", "bestEpoch", "bestValMse",
+		"maxGradientNorm", "trainSeconds")
+	var err error
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".arrow", ".feather":
+		l.arrow, err = data.CreateArrow(path, detailedLogColumns(header))
+		header = nil //the schema names the columns
+	default:
+		l.file, err = data.CreateAtomic(path)
+		l.compressed = strings.HasSuffix(path, ".gz")
+	}
+	if err != nil {
+		log.Fatal("Error: cannot create detailed log file", err)
+	}
+	go l.write(header)
+	return l
+}
+
+// Types the columns of an Arrow detailed log: its categorical hyperparameters and outpath are strings, fitIntercept
+// is a bool, epochs are integers and everything else is a float, with NA as null
+func detailedLogColumns(header []string) []data.ArrowColumn {
+	columns := make([]data.ArrowColumn, len(header))
+	for i, name := range header {
+		columns[i] = data.ArrowColumn{Name: name, Type: data.ArrowFloat64}
+		switch name {
+		case "outpath", "optimizer", "schedule", "loss", "link", "target", "nonNegative":
+			columns[i].Type = data.ArrowUtf8
+		case "fitIntercept":
+			columns[i].Type = data.ArrowBool
+		case "convergedEpoch", "bestEpoch":
+			columns[i].Type = data.ArrowInt64
+		}
+	}
+	return columns
+}
+
+// Writes batches of rows, after the header row of a csv log, until the channel is closed, then the last batch, and
+// reports the first write error
+func (l *detailedLog) write(header []string) {
+	var writer *csv.Writer
+	var gzipWriter *gzip.Writer
+	var batch [][]string
+	if l.file != nil {
+		var w io.Writer = l.file
+		if l.compressed {
+			gzipWriter = gzip.NewWriter(l.file)
+			w = gzipWriter
+		}
+		writer = csv.NewWriter(w)
+		batch = append(batch, header)
+	}
+	var err error
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if writer != nil {
+			writer.WriteAll(batch) //flushes
+		} else if batchErr := l.arrow.WriteBatch(batch); err == nil {
+			err = batchErr
+		}
+		batch = batch[:0]
+	}
+	ticker := time.NewTicker(l.flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case row, ok := <- l.rows:
+			if !ok {
+				flush()
+				if writer != nil {
+					err = writer.Error()
+				}
+				if gzipWriter != nil && err == nil {
+					err = gzipWriter.Close()
+				}
+				l.finished <- err
+				return
+			}
+			batch = append(batch, row)
+			if len(batch) >= l.batchRows {
+				flush()
+			}
+		case <- ticker.C:
+			flush()
+		}
+	}
+}
+
+// Records one evaluated permutation. The max gradient norm is the largest full gradient norm seen at the start of
+// an epoch on normalized x; values far above the others flag alphas on the edge of divergence. The training time of
+// each permutation weighs what deeper epoch grids cost against the MSE they gain
+func (l *detailedLog) record(hyperParams Hyperparameters, parameters regression.Parameters, mse float64, stats gridsearch.Stats) {
+	convergedWrite := "NA"
+	if stats.ConvergedEpoch >= 0 {
+		convergedWrite = strconv.Itoa(stats.ConvergedEpoch)
+	}
+	row := append(append([]string{hyperParams.Outpath}, hyperparamColumns(hyperParams)...), fmt.Sprintf("%f", parameters.Beta),
+		fmt.Sprintf("%f", parameters.Mu), fmt.Sprintf("%f", mse), convergedWrite)
+	row = append(row, validationColumns(stats)...)
+	row = append(row, fmt.Sprintf("%f", stats.MaxGradientNorm), fmt.Sprintf("%f", stats.Seconds))
+	l.rows <- row
+}
+
+// Flushes the detailed log and renames it into place. Until then it is a temporary file, so a log whose run crashed
+// is never mistaken for a complete one
+func (l *detailedLog) Close() {
+	close(l.rows)
+	err := <- l.finished
+	if err == nil && l.arrow != nil {
+		err = l.arrow.Commit()
+	} else if err == nil {
+		err = l.file.Commit()
+	}
+	if err != nil {
+		log.Fatal("Error: cannot write detailed log file ", err)
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+)
+
+// Environment variable holding a whole run's configuration, so a container, eg a Kubernetes Job, can be launched
+// without mounted files or stdin
+const configEnv = "CALIBRATE_CONFIG_JSON"
+
+// A run's configuration as given in CALIBRATE_CONFIG_JSON, eg
+// {"flags": {"i": "data.csv", "t": 8, "scale": "standard"}, "tasks": [{"outpath": "results.csv", "alpha": ["0.1"], ...}]}
+// Flags are named as on the command line, without the dash, and tasks are the JSON tasks stdin would hold
+type envConfig struct {
+	Flags map[string]interface{} `json:"flags"`
+	Tasks []json.RawMessage `json:"tasks"`
+}
+
+// Applies the flags of CALIBRATE_CONFIG_JSON, if it is set, to the parsed command line: flags given on the command
+// line win. Returns the config's tasks, or nil if it has none
+func applyEnvConfig() io.Reader {
+	raw := os.Getenv(configEnv)
+	if raw == "" {
+		return nil
+	}
+	var config envConfig
+	dec := json.NewDecoder(bytes.NewReader([]byte(raw)))
+	dec.UseNumber() //so numbers are set as written, eg 100000 rather than 1e+05
+	if err := dec.Decode(&config); err != nil {
+		log.Fatal("Error: invalid ", configEnv, ": ", err)
+	}
+	given := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { given[f.Name] = true })
+	for name, value := range config.Flags {
+		if flag.Lookup(name) == nil {
+			log.Fatal("Error: unknown flag ", name, " in ", configEnv)
+		}
+		if given[name] {
+			continue
+		}
+		if err := flag.Set(name, fmt.Sprint(value)); err != nil {
+			log.Fatal("Error: invalid flag ", name, " in ", configEnv, ": ", err)
+		}
+	}
+	if config.Tasks == nil {
+		return nil
+	}
+	var tasks bytes.Buffer
+	for _, task := range config.Tasks {
+		tasks.Write(task)
+		tasks.WriteByte('\n')
+	}
+	return &tasks
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"proj3/data"
+	"strconv"
+)
+
+// Row counts of the benchmark dataset suite, a ladder of powers of ten
+var suiteSizes = []int{10000, 100000, 1000000, 10000000, 100000000}
+
+// Entry point of the generate subcommand: calibrate generate -suite -dir="training_data" -t=number of threads
+// Generates the standard benchmark datasets. Every size is seeded with its own row count, so the suite is identical
+// on every machine and benchmark runs stay comparable
+func generate(args []string) {
+	flags := flag.NewFlagSet("generate", flag.ExitOnError)
+	suite := flags.Bool("suite", false, "generate the benchmark dataset suite")
+	dir := flags.String("dir", ".", "directory the suite is written into")
+	maxRows := flags.Int("max", suiteSizes[len(suiteSizes) - 1], "largest dataset of the suite to generate")
+	numThreads := flags.Int("t", 0, "number of threads generating each dataset")
+	flags.Parse(args)
+	if !*suite {
+		fmt.Println("Usage: calibrate generate -suite -dir=\"directory\" -max=largest row count -t=number of threads")
+		os.Exit(0)
+	}
+
+	for _, n := range suiteSizes {
+		if n > *maxRows {
+			break
+		}
+		outpath := filepath.Join(*dir, "trainingData_" + strconv.Itoa(n) + ".csv")
+		data.GenerateTrainingData(n, outpath, data.GenerateOptions{NumWorkers: *numThreads, Seed: int64(n)})
+		fmt.Println("Generated training data into filepath:", outpath)
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"log"
+	"net"
+	"net/http"
+	"sync/atomic"
+)
+
+// Health endpoints of a calibrate instance for orchestrators: /healthz answers 200 while the process is alive, and
+// /readyz answers 200 only while the training data is loaded and the search is taking tasks, else 503
+type health struct {
+	ready atomic.Bool
+}
+
+// Starts serving the health endpoints on addr, eg ":8080", in the background. The instance starts out not ready
+func serveHealth(addr string) *health {
+	h := &health{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok\n"))
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		if !h.ready.Load() {
+			http.Error(w, "not ready", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ready\n"))
+	})
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		log.Fatal("Error: cannot listen for health checks on ", addr, ": ", err)
+	}
+	go http.Serve(listener, mux)
+	return h
+}
+
+// Marks the instance ready or not. A nil health, when no -health-addr is given, ignores it
+func (h *health) setReady(ready bool) {
+	if h == nil {
+		return
+	}
+	h.ready.Store(ready)
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"proj3/data"
+	"sync"
+)
+
+// A crash-recovery journal of a run in a directory, so a run killed by an OOM or a node failure can be resumed with
+// -resume, losing no more than the permutations that were training. It holds the run's tasks as decoded, in
+// tasks.jsonl; the result of every permutation trained, in the result cache journals under results/, which is also
+// where a task's best-so-far is recovered from; and the outpath of every finished task, in done.txt. Results and
+// finished tasks are appended as they happen rather than flushed periodically, as both are small. A nil journal
+// records nothing
+type recoveryJournal struct {
+	lock sync.Mutex
+	dir string
+	done map[string]bool // tasks finished before a resume
+	doneFile *os.File
+}
+
+// Opens the journal in dir. A new run records its tasks, read from input, into it; a resumed run reads the tasks and
+// the finished ones back instead. Returns the journal and the tasks to search
+func openRecoveryJournal(dir string, resume bool, input io.Reader) (*recoveryJournal, io.Reader) {
+	tasksPath := filepath.Join(dir, "tasks.jsonl")
+	donePath := filepath.Join(dir, "done.txt")
+	j := &recoveryJournal{dir: dir, done: make(map[string]bool)}
+	if resume {
+		tasks, err := os.ReadFile(tasksPath)
+		if err != nil {
+			log.Fatal("Error: cannot resume from journal ", dir, ": ", err)
+		}
+		input = bytes.NewReader(tasks)
+		if file, err := os.Open(donePath); err == nil {
+			scanner := bufio.NewScanner(file)
+			for scanner.Scan() {
+				j.done[scanner.Text()] = true
+			}
+			file.Close()
+		}
+	} else {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			log.Fatal("Error: cannot create journal ", dir, ": ", err)
+		}
+		tasks, err := io.ReadAll(input)
+		if err != nil {
+			log.Fatal("Error: cannot read tasks: ", err)
+		}
+		if err := data.WriteFileAtomic(tasksPath, tasks); err != nil {
+			log.Fatal("Error: cannot write journal ", tasksPath, ": ", err)
+		}
+		os.Remove(donePath)
+		input = bytes.NewReader(tasks)
+	}
+	doneFile, err := os.OpenFile(donePath, os.O_WRONLY | os.O_CREATE | os.O_APPEND, 0644)
+	if err != nil {
+		log.Fatal("Error: cannot open journal ", donePath, ": ", err)
+	}
+	j.doneFile = doneFile
+	return j, input
+}
+
+// Directory of the result cache journals of the run's trained permutations
+func (j *recoveryJournal) resultsDir() string {
+	return filepath.Join(j.dir, "results")
+}
+
+// Whether a task finished before the run was resumed
+func (j *recoveryJournal) finishedBefore(outpath string) bool {
+	return j != nil && j.done[outpath]
+}
+
+// Records a finished task, syncing so it survives a node failure
+func (j *recoveryJournal) taskFinished(outpath string) {
+	if j == nil {
+		return
+	}
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	_, err := j.doneFile.WriteString(outpath + "\n")
+	if err == nil {
+		err = j.doneFile.Sync()
+	}
+	if err != nil {
+		log.Println("Warning: cannot record finished task", outpath, "in the journal -", err)
+	}
+}
+
+// Closes the journal
+func (j *recoveryJournal) Close() {
+	if j == nil {
+		return
+	}
+	j.doneFile.Close()
+}
@@ -0,0 +1,948 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Limits of the requests the Kafka queue sends
+const (
+	kafkaRequestTimeout = 30 * time.Second
+	kafkaFetchWait = 500 // milliseconds a fetch waits on the broker for new tasks
+	kafkaFetchBytes = 1 << 20
+	kafkaRetryDelay = time.Second // wait before retrying a partition whose leader moved
+)
+
+// A Kafka request: its API key and the version sent, which are the non-flexible versions that brokers from 1.0
+// through 4.x all answer
+type kafkaAPI struct {
+	key int16
+	version int16
+}
+
+var (
+	kafkaProduce = kafkaAPI{0, 3}
+	kafkaFetch = kafkaAPI{1, 4}
+	kafkaListOffsets = kafkaAPI{2, 1}
+	kafkaMetadata = kafkaAPI{3, 4}
+	kafkaOffsetCommit = kafkaAPI{8, 2}
+	kafkaOffsetFetch = kafkaAPI{9, 3}
+	kafkaFindCoordinator = kafkaAPI{10, 1}
+)
+
+// Kafka error codes the queue recovers from
+const (
+	kafkaOffsetOutOfRange = 1
+	kafkaUnknownTopicOrPartition = 3
+	kafkaLeaderNotAvailable = 5
+	kafkaNotLeader = 6
+	kafkaCoordinatorLoading = 14
+	kafkaCoordinatorNotAvailable = 15
+	kafkaNotCoordinator = 16
+)
+
+// Checksum of record batches
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// A Kafka queue: tasks are consumed from the partitions of the tasks topic, one JSON task per message, and results
+// are produced to partition 0 of the results topic. The offset of a task is committed to the consumer group only once
+// the task and every task before it on its partition are searched or skipped, so the tasks a crashed worker had taken
+// are consumed again by the next worker of the group. Workers do not join the group's rebalancing: each consumes the
+// partitions its url lists, all of them by default, so a fleet splits a topic by listing disjoint partitions
+type kafkaQueue struct {
+	tasks string
+	results string
+	group string
+	partitions []int32 // partitions of the tasks topic consumed
+	brokers map[int32]string // address of every broker by node id
+	leaders map[string]map[int32]int32 // leader of every partition by topic
+	next map[int32]int64 // offset of the next task fetched, by partition
+	buffered []kafkaRecord // tasks fetched but not yet pulled
+
+	connLock sync.Mutex
+	bootstrap *kafkaConn
+	fetchers map[int32]*kafkaConn // connections to the leaders of the consumed partitions, by node id
+	coordinator *kafkaConn
+	producer *kafkaConn
+	stopped bool
+
+	lock sync.Mutex // guards last, pending and inflight
+	last *kafkaMessage // the task pulled last
+	pending map[string]*kafkaMessage // tasks queued for the search by outpath, which pullTasks keeps unique
+	inflight map[int32][]*kafkaMessage // tasks pulled but not yet committed, by partition in offset order
+}
+
+// A task the queue pulled
+type kafkaMessage struct {
+	partition int32
+	offset int64
+	done bool // searched or skipped
+}
+
+// A fetched record
+type kafkaRecord struct {
+	partition int32
+	offset int64
+	value []byte
+}
+
+// A transaction a fetch reports as aborted: the records of its producer from firstOffset on, up to the producer's
+// abort marker, were never committed
+type kafkaAbortedTransaction struct {
+	producerID int64
+	firstOffset int64
+}
+
+// Connects to the cluster of a kafka://host:9092?group=calibrate&partitions=0,1 url, consuming the given partitions of
+// the tasks topic, all of them if it names none, from the offsets the group committed, or the start of a partition
+// the group never committed to
+func dialKafkaQueue(parsed *url.URL, tasks string, results string) (*kafkaQueue, error) {
+	q := &kafkaQueue{tasks: tasks, results: results, group: parsed.Query().Get("group"), fetchers: make(map[int32]*kafkaConn),
+		next: make(map[int32]int64), pending: make(map[string]*kafkaMessage), inflight: make(map[int32][]*kafkaMessage)}
+	if q.group == "" {
+		q.group = "calibrate"
+	}
+	var err error
+	if q.bootstrap, err = dialKafka(queueAddr(parsed, "9092")); err != nil {
+		return nil, err
+	}
+	if err = q.refreshMetadata(); err != nil {
+		q.Close()
+		return nil, err
+	}
+	if listed := parsed.Query().Get("partitions"); listed != "" {
+		for _, field := range strings.Split(listed, ",") {
+			partition, err := strconv.Atoi(strings.TrimSpace(field))
+			if _, ok := q.leaders[tasks][int32(partition)]; err != nil || !ok {
+				q.Close()
+				return nil, fmt.Errorf("topic %s has no partition %s", tasks, field)
+			}
+			q.partitions = append(q.partitions, int32(partition))
+		}
+	} else {
+		for partition := range q.leaders[tasks] {
+			q.partitions = append(q.partitions, partition)
+		}
+	}
+	if err = q.findCoordinator(); err == nil {
+		err = q.fetchCommitted()
+	}
+	if err != nil {
+		q.Close()
+		return nil, err
+	}
+	return q, nil
+}
+
+// Connects to the cluster of a kafka://host:9092 url only to produce to topic, for -publish
+func dialKafkaProducer(parsed *url.URL, topic string) (*kafkaQueue, error) {
+	q := &kafkaQueue{results: topic, fetchers: make(map[int32]*kafkaConn)}
+	var err error
+	if q.bootstrap, err = dialKafka(queueAddr(parsed, "9092")); err != nil {
+		return nil, err
+	}
+	if err = q.refreshMetadata(); err != nil {
+		q.Close()
+		return nil, err
+	}
+	return q, nil
+}
+
+// Reads the brokers and the partition leaders of the tasks and results topics, which must exist. A queue that only
+// produces has no tasks topic
+func (q *kafkaQueue) refreshMetadata() error {
+	topics := []string{q.results}
+	if q.tasks != "" {
+		topics = append(topics, q.tasks)
+	}
+	var request kafkaWriter
+	request.array(len(topics))
+	for _, topic := range topics {
+		request.str(topic)
+	}
+	request.int8(0) //topics are not created on the fly
+	response, err := q.bootstrap.request(kafkaMetadata, request.Bytes())
+	if err != nil {
+		return err
+	}
+	response.int32() //throttle time
+	brokers := make(map[int32]string)
+	for i := response.array(); i > 0; i-- {
+		node, host, port := response.int32(), response.str(), response.int32()
+		response.str() //rack
+		brokers[node] = net.JoinHostPort(host, strconv.Itoa(int(port)))
+	}
+	response.str() //cluster id
+	response.int32() //controller
+	leaders := make(map[string]map[int32]int32)
+	for i := response.array(); i > 0; i-- {
+		code, topic := response.int16(), response.str()
+		response.int8() //internal
+		if code != 0 {
+			return fmt.Errorf("topic %s: %s", topic, kafkaError(code))
+		}
+		leaders[topic] = make(map[int32]int32)
+		for j := response.array(); j > 0; j-- {
+			response.int16() //a partition without a leader is retried once fetched
+			partition, leader := response.int32(), response.int32()
+			response.int32s() //replicas
+			response.int32s() //in sync replicas
+			leaders[topic][partition] = leader
+		}
+	}
+	if response.err != nil {
+		return response.err
+	}
+	for _, topic := range topics {
+		if len(leaders[topic]) == 0 {
+			return fmt.Errorf("topic %s has no partitions", topic)
+		}
+	}
+	q.connLock.Lock()
+	defer q.connLock.Unlock()
+	q.brokers, q.leaders = brokers, leaders
+	return nil
+}
+
+// Connects to the group's coordinator, which stores its offsets
+func (q *kafkaQueue) findCoordinator() error {
+	var request kafkaWriter
+	request.str(q.group)
+	request.int8(0) //a group rather than a transaction
+	response, err := q.bootstrap.request(kafkaFindCoordinator, request.Bytes())
+	if err != nil {
+		return err
+	}
+	response.int32() //throttle time
+	code := response.int16()
+	response.str() //error message
+	response.int32() //node id
+	host, port := response.str(), response.int32()
+	if response.err != nil {
+		return response.err
+	}
+	if code != 0 {
+		return fmt.Errorf("cannot find the coordinator of group %s: %s", q.group, kafkaError(code))
+	}
+	coordinator, err := dialKafka(net.JoinHostPort(host, strconv.Itoa(int(port))))
+	if err != nil {
+		return err
+	}
+	q.connLock.Lock()
+	defer q.connLock.Unlock()
+	if q.coordinator != nil {
+		q.coordinator.conn.Close()
+	}
+	q.coordinator = coordinator
+	return nil
+}
+
+// Starts every consumed partition from the offset the group committed, or from its earliest offset
+func (q *kafkaQueue) fetchCommitted() error {
+	var request kafkaWriter
+	request.str(q.group)
+	request.array(1)
+	request.str(q.tasks)
+	request.array(len(q.partitions))
+	for _, partition := range q.partitions {
+		request.int32(partition)
+	}
+	response, err := q.coordinator.request(kafkaOffsetFetch, request.Bytes())
+	if err != nil {
+		return err
+	}
+	response.int32() //throttle time
+	for i := response.array(); i > 0; i-- {
+		response.str() //topic
+		for j := response.array(); j > 0; j-- {
+			partition, offset := response.int32(), response.int64()
+			response.str() //metadata
+			if code := response.int16(); code != 0 {
+				return fmt.Errorf("cannot fetch the offsets of group %s: %s", q.group, kafkaError(code))
+			}
+			q.next[partition] = offset
+		}
+	}
+	if code := response.int16(); response.err == nil && code != 0 {
+		return fmt.Errorf("cannot fetch the offsets of group %s: %s", q.group, kafkaError(code))
+	}
+	if response.err != nil {
+		return response.err
+	}
+	for _, partition := range q.partitions {
+		if offset, ok := q.next[partition]; !ok || offset < 0 {
+			if err := q.resetOffset(partition); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Starts a partition from its earliest offset, for a group that never committed to it or whose offset is deleted
+func (q *kafkaQueue) resetOffset(partition int32) error {
+	conn, err := q.leaderConn(partition)
+	if err != nil {
+		return err
+	}
+	var request kafkaWriter
+	request.int32(-1) //a consumer rather than a replica
+	request.array(1)
+	request.str(q.tasks)
+	request.array(1)
+	request.int32(partition)
+	request.int64(-2) //the earliest offset
+	response, err := conn.request(kafkaListOffsets, request.Bytes())
+	if err != nil {
+		return err
+	}
+	for i := response.array(); i > 0; i-- {
+		response.str() //topic
+		for j := response.array(); j > 0; j-- {
+			listed, code := response.int32(), response.int16()
+			response.int64() //timestamp
+			offset := response.int64()
+			if code != 0 {
+				return fmt.Errorf("cannot list the offsets of partition %d: %s", listed, kafkaError(code))
+			}
+			q.next[listed] = offset
+		}
+	}
+	return response.err
+}
+
+// Returns the connection to the leader of a consumed partition, connecting to it first if need be
+func (q *kafkaQueue) leaderConn(partition int32) (*kafkaConn, error) {
+	q.connLock.Lock()
+	defer q.connLock.Unlock()
+	if q.stopped {
+		return nil, errors.New("stopped pulling")
+	}
+	leader := q.leaders[q.tasks][partition]
+	if conn, ok := q.fetchers[leader]; ok {
+		return conn, nil
+	}
+	addr, ok := q.brokers[leader]
+	if !ok {
+		return nil, fmt.Errorf("partition %d has no leader", partition)
+	}
+	conn, err := dialKafka(addr)
+	if err != nil {
+		return nil, err
+	}
+	q.fetchers[leader] = conn
+	return conn, nil
+}
+
+func (q *kafkaQueue) pull() ([]byte, error) {
+	for len(q.buffered) == 0 {
+		if err := q.fetch(); err != nil {
+			return nil, err
+		}
+	}
+	record := q.buffered[0]
+	q.buffered = q.buffered[1:]
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	q.last = &kafkaMessage{partition: record.partition, offset: record.offset}
+	q.inflight[record.partition] = append(q.inflight[record.partition], q.last)
+	return record.value, nil
+}
+
+// Fetches the tasks after the next offset of every consumed partition, with one request to each leader that waits on
+// the broker for up to kafkaFetchWait if there are none
+func (q *kafkaQueue) fetch() error {
+	byLeader := make(map[int32][]int32)
+	q.connLock.Lock()
+	for _, partition := range q.partitions {
+		leader := q.leaders[q.tasks][partition]
+		byLeader[leader] = append(byLeader[leader], partition)
+	}
+	q.connLock.Unlock()
+	for _, partitions := range byLeader {
+		conn, err := q.leaderConn(partitions[0])
+		if err != nil {
+			return err
+		}
+		var request kafkaWriter
+		request.int32(-1) //a consumer rather than a replica
+		request.int32(kafkaFetchWait)
+		request.int32(1) //min bytes
+		request.int32(kafkaFetchBytes)
+		request.int8(1) //read committed, so tasks of open or aborted transactions are not trained
+		request.array(1)
+		request.str(q.tasks)
+		request.array(len(partitions))
+		for _, partition := range partitions {
+			request.int32(partition)
+			request.int64(q.next[partition])
+			request.int32(kafkaFetchBytes)
+		}
+		response, err := conn.request(kafkaFetch, request.Bytes())
+		if err != nil {
+			return err
+		}
+		response.int32() //throttle time
+		moved := false
+		for i := response.array(); i > 0; i-- {
+			response.str() //topic
+			for j := response.array(); j > 0; j-- {
+				partition, code := response.int32(), response.int16()
+				response.int64() //high watermark
+				response.int64() //last stable offset
+				var aborted []kafkaAbortedTransaction
+				for k := response.array(); k > 0; k-- {
+					aborted = append(aborted, kafkaAbortedTransaction{producerID: response.int64(), firstOffset: response.int64()})
+				}
+				records := response.bytes()
+				switch code {
+				case 0:
+				case kafkaOffsetOutOfRange: //the tasks at the next offset were deleted by retention
+					log.Println("Warning: offset", q.next[partition], "of partition", partition, "is gone, consuming from its earliest offset")
+					if err := q.resetOffset(partition); err != nil {
+						return err
+					}
+					continue
+				case kafkaUnknownTopicOrPartition, kafkaLeaderNotAvailable, kafkaNotLeader:
+					moved = true
+					continue
+				default:
+					return fmt.Errorf("cannot fetch partition %d: %s", partition, kafkaError(code))
+				}
+				fetched, next, err := decodeRecordBatches(records, q.next[partition], aborted)
+				if err != nil {
+					return fmt.Errorf("partition %d: %v", partition, err)
+				}
+				for _, record := range fetched {
+					record.partition = partition
+					q.buffered = append(q.buffered, record)
+				}
+				q.next[partition] = next //past control and aborted batches too, which decode to no tasks
+			}
+		}
+		if response.err != nil {
+			return response.err
+		}
+		if moved {
+			time.Sleep(kafkaRetryDelay)
+			if err := q.refreshMetadata(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Ends pulling by closing the connections fetches are blocked on. Offsets are still committed as the tasks taken
+// finish
+func (q *kafkaQueue) stopPulling() {
+	q.connLock.Lock()
+	defer q.connLock.Unlock()
+	q.stopped = true
+	for _, conn := range q.fetchers {
+		conn.conn.Close()
+	}
+}
+
+func (q *kafkaQueue) publish(payload []byte) error {
+	q.connLock.Lock()
+	if q.producer == nil {
+		addr, ok := q.brokers[q.leaders[q.results][0]]
+		if !ok {
+			q.connLock.Unlock()
+			return fmt.Errorf("partition 0 of %s has no leader", q.results)
+		}
+		producer, err := dialKafka(addr)
+		if err != nil {
+			q.connLock.Unlock()
+			return err
+		}
+		q.producer = producer
+	}
+	producer := q.producer
+	q.connLock.Unlock()
+
+	var request kafkaWriter
+	request.int16(-1) //no transactional id
+	request.int16(1) //acknowledged by the leader
+	request.int32(int32(kafkaRequestTimeout / time.Millisecond))
+	request.array(1)
+	request.str(q.results)
+	request.array(1)
+	request.int32(0)
+	request.bytes(encodeRecordBatch(payload))
+	response, err := producer.request(kafkaProduce, request.Bytes())
+	if err != nil {
+		return err
+	}
+	for i := response.array(); i > 0; i-- {
+		response.str() //topic
+		for j := response.array(); j > 0; j-- {
+			response.int32() //partition
+			if code := response.int16(); code != 0 {
+				return fmt.Errorf("cannot produce to %s: %s", q.results, kafkaError(code))
+			}
+			response.int64() //base offset
+			response.int64() //log append time
+		}
+	}
+	return response.err
+}
+
+func (q *kafkaQueue) Close() {
+	q.connLock.Lock()
+	defer q.connLock.Unlock()
+	for _, conn := range append([]*kafkaConn{q.bootstrap, q.coordinator, q.producer}, mapValues(q.fetchers)...) {
+		if conn != nil {
+			conn.conn.Close()
+		}
+	}
+}
+
+func mapValues(conns map[int32]*kafkaConn) []*kafkaConn {
+	values := make([]*kafkaConn, 0, len(conns))
+	for _, conn := range conns {
+		values = append(values, conn)
+	}
+	return values
+}
+
+// Records that the task pulled last is queued for the search under outpath, which no other queued task has. A nil
+// queue ignores it
+func (q *kafkaQueue) taken(outpath string) {
+	if q == nil {
+		return
+	}
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	q.pending[outpath] = q.last
+}
+
+// Records that the task pulled last is not searched, being invalid, so its offset is committed. A nil queue ignores it
+func (q *kafkaQueue) skipped() {
+	if q == nil {
+		return
+	}
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	q.last.done = true
+	q.commit(q.last.partition)
+}
+
+// Records that the task of outpath is searched, or skipped by the search, committing its offset once the tasks
+// before it on its partition are too. A nil queue ignores it
+func (q *kafkaQueue) finished(outpath string) {
+	if q == nil {
+		return
+	}
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	message, ok := q.pending[outpath]
+	if !ok {
+		return
+	}
+	message.done = true
+	delete(q.pending, outpath)
+	q.commit(message.partition)
+}
+
+// Commits the offset after the tasks of a partition that are done with no task before them still searched. Called
+// under q.lock; a commit that fails is logged, as the next one covers its tasks too
+func (q *kafkaQueue) commit(partition int32) {
+	inflight := q.inflight[partition]
+	committed := int64(-1)
+	for len(inflight) > 0 && inflight[0].done {
+		committed = inflight[0].offset + 1
+		inflight = inflight[1:]
+	}
+	q.inflight[partition] = inflight
+	if committed < 0 {
+		return
+	}
+	err := q.commitOffset(partition, committed)
+	if isCoordinatorMoved(err) {
+		if err = q.findCoordinator(); err == nil {
+			err = q.commitOffset(partition, committed)
+		}
+	}
+	if err != nil {
+		log.Println("Warning: cannot commit offset", committed, "of partition", partition, "-", err)
+	}
+}
+
+// Error of a commit the coordinator did not take because it moved
+type kafkaCodeError struct {
+	code int16
+}
+
+func (e kafkaCodeError) Error() string {
+	return kafkaError(e.code)
+}
+
+// Whether a commit failed because the group's coordinator moved or its connection broke, so it is retried on the
+// coordinator found anew
+func isCoordinatorMoved(err error) bool {
+	var codeErr kafkaCodeError
+	if errors.As(err, &codeErr) {
+		return codeErr.code == kafkaCoordinatorLoading || codeErr.code == kafkaCoordinatorNotAvailable || codeErr.code == kafkaNotCoordinator
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.EOF)
+}
+
+func (q *kafkaQueue) commitOffset(partition int32, offset int64) error {
+	var request kafkaWriter
+	request.str(q.group)
+	request.int32(-1) //generation of a consumer outside the group's rebalancing
+	request.str("") //member id
+	request.int64(-1) //the broker's retention time
+	request.array(1)
+	request.str(q.tasks)
+	request.array(1)
+	request.int32(partition)
+	request.int64(offset)
+	request.str("") //metadata
+	q.connLock.Lock()
+	coordinator := q.coordinator
+	q.connLock.Unlock()
+	response, err := coordinator.request(kafkaOffsetCommit, request.Bytes())
+	if err != nil {
+		return err
+	}
+	for i := response.array(); i > 0; i-- {
+		response.str() //topic
+		for j := response.array(); j > 0; j-- {
+			response.int32() //partition
+			if code := response.int16(); code != 0 {
+				return kafkaCodeError{code}
+			}
+		}
+	}
+	return response.err
+}
+
+// Describes a Kafka error code
+func kafkaError(code int16) string {
+	names := map[int16]string{kafkaOffsetOutOfRange: "offset out of range", kafkaUnknownTopicOrPartition: "unknown topic or partition",
+		kafkaLeaderNotAvailable: "leader not available", kafkaNotLeader: "not the leader", kafkaCoordinatorLoading: "coordinator loading",
+		kafkaCoordinatorNotAvailable: "coordinator not available", kafkaNotCoordinator: "not the coordinator", 25: "unknown member id",
+		29: "topic authorization failed", 30: "group authorization failed"}
+	if name, ok := names[code]; ok {
+		return fmt.Sprintf("%s (error %d)", name, code)
+	}
+	return fmt.Sprintf("error %d", code)
+}
+
+// A connection speaking the Kafka protocol, whose requests are sent one at a time
+type kafkaConn struct {
+	conn net.Conn
+	reader *bufio.Reader
+	lock sync.Mutex
+	correlation int32
+}
+
+func dialKafka(addr string) (*kafkaConn, error) {
+	conn, err := net.DialTimeout("tcp", addr, queueDialTimeout)
+	if err != nil {
+		return nil, err
+	}
+	return &kafkaConn{conn: conn, reader: bufio.NewReader(conn)}, nil
+}
+
+// Sends a request and returns the body of its response
+func (c *kafkaConn) request(api kafkaAPI, body []byte) (*kafkaReader, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.correlation++
+	var frame kafkaWriter
+	frame.int32(int32(10 + len(kafkaClientID) + len(body)))
+	frame.int16(api.key)
+	frame.int16(api.version)
+	frame.int32(c.correlation)
+	frame.str(kafkaClientID)
+	frame.Write(body)
+	c.conn.SetDeadline(time.Now().Add(kafkaRequestTimeout))
+	if _, err := c.conn.Write(frame.Bytes()); err != nil {
+		return nil, err
+	}
+	var size int32
+	if err := binary.Read(c.reader, binary.BigEndian, &size); err != nil {
+		return nil, err
+	}
+	response := make([]byte, size)
+	if _, err := io.ReadFull(c.reader, response); err != nil {
+		return nil, err
+	}
+	reader := &kafkaReader{data: response}
+	if correlation := reader.int32(); correlation != c.correlation {
+		return nil, fmt.Errorf("response %d to request %d", correlation, c.correlation)
+	}
+	return reader, nil
+}
+
+// Client id the queue sends with its requests
+const kafkaClientID = "calibrate worker"
+
+// Encodes the fields of a Kafka request, big endian
+type kafkaWriter struct {
+	bytes.Buffer
+}
+
+func (w *kafkaWriter) int8(value int8) {
+	w.WriteByte(byte(value))
+}
+
+func (w *kafkaWriter) int16(value int16) {
+	binary.Write(w, binary.BigEndian, value)
+}
+
+func (w *kafkaWriter) int32(value int32) {
+	binary.Write(w, binary.BigEndian, value)
+}
+
+func (w *kafkaWriter) int64(value int64) {
+	binary.Write(w, binary.BigEndian, value)
+}
+
+func (w *kafkaWriter) str(value string) {
+	w.int16(int16(len(value)))
+	w.WriteString(value)
+}
+
+func (w *kafkaWriter) bytes(value []byte) {
+	w.int32(int32(len(value)))
+	w.Write(value)
+}
+
+func (w *kafkaWriter) array(length int) {
+	w.int32(int32(length))
+}
+
+// Encodes a zigzag varint, as records encode their fields
+func (w *kafkaWriter) varint(value int64) {
+	w.Write(binary.AppendVarint(nil, value))
+}
+
+// Decodes the fields of a Kafka response. The first field that runs past the end sets err, after which every field
+// reads as zero
+type kafkaReader struct {
+	data []byte
+	err error
+}
+
+func (r *kafkaReader) next(n int) []byte {
+	if r.err != nil || n < 0 || n > len(r.data) {
+		if r.err == nil {
+			r.err = errors.New("truncated kafka response")
+		}
+		if n < 0 || n > 8 { //only fixed size fields read on as zero
+			n = 0
+		}
+		return make([]byte, n)
+	}
+	field := r.data[:n]
+	r.data = r.data[n:]
+	return field
+}
+
+func (r *kafkaReader) int8() int8 {
+	return int8(r.next(1)[0])
+}
+
+func (r *kafkaReader) int16() int16 {
+	return int16(binary.BigEndian.Uint16(r.next(2)))
+}
+
+func (r *kafkaReader) int32() int32 {
+	return int32(binary.BigEndian.Uint32(r.next(4)))
+}
+
+func (r *kafkaReader) int64() int64 {
+	return int64(binary.BigEndian.Uint64(r.next(8)))
+}
+
+// Reads a string, "" if it is null
+func (r *kafkaReader) str() string {
+	length := r.int16()
+	if length < 0 {
+		return ""
+	}
+	return string(r.next(int(length)))
+}
+
+// Reads bytes, nil if they are null
+func (r *kafkaReader) bytes() []byte {
+	length := r.int32()
+	if length < 0 {
+		return nil
+	}
+	return r.next(int(length))
+}
+
+// Reads the length of an array, 0 if it is null
+func (r *kafkaReader) array() int {
+	length := r.int32()
+	if length < 0 || r.err != nil {
+		return 0
+	}
+	return int(length)
+}
+
+func (r *kafkaReader) int32s() {
+	for i := r.array(); i > 0; i-- {
+		r.int32()
+	}
+}
+
+func (r *kafkaReader) varint() int64 {
+	if r.err != nil {
+		return 0
+	}
+	value, n := binary.Varint(r.data)
+	if n <= 0 {
+		r.err = errors.New("malformed varint in kafka response")
+		return 0
+	}
+	r.data = r.data[n:]
+	return value
+}
+
+// Decodes the records at or after offset from of a fetched record set, skipping transaction markers and the batches
+// of the aborted transactions. A record set holds record batches of the format of Kafka 0.11 and later, and may end
+// with a partial batch cut by the fetch's size limit. Also returns the offset to fetch next: past the last whole batch,
+// even one that decoded to no records, so a partition ending in a marker is not fetched again and again
+func decodeRecordBatches(records []byte, from int64, aborted []kafkaAbortedTransaction) ([]kafkaRecord, int64, error) {
+	var decoded []kafkaRecord
+	next := from
+	sort.Slice(aborted, func(i, j int) bool { return aborted[i].firstOffset < aborted[j].firstOffset })
+	abortedProducers := make(map[int64]bool) // producers in an aborted transaction at the current batch
+	for len(records) >= 12 {
+		baseOffset := int64(binary.BigEndian.Uint64(records))
+		length := int(int32(binary.BigEndian.Uint32(records[8:])))
+		if length < 0 || len(records) < 12 + length {
+			break
+		}
+		batch := &kafkaReader{data: records[12 : 12 + length]}
+		records = records[12 + length:]
+		batch.int32() //partition leader epoch
+		if magic := batch.int8(); magic != 2 {
+			return nil, from, fmt.Errorf("record format v%d is not supported, only v2 of Kafka 0.11 and later", magic)
+		}
+		checksum := uint32(batch.int32())
+		if batch.err == nil && crc32.Checksum(batch.data, castagnoli) != checksum {
+			return nil, from, fmt.Errorf("corrupt record batch at offset %d", baseOffset)
+		}
+		attributes := batch.int16()
+		lastOffset := baseOffset + int64(batch.int32())
+		batch.next(8 + 8) //timestamps
+		producerID := batch.int64()
+		batch.next(2 + 4) //producer epoch, base sequence
+		count := batch.int32()
+		if batch.err != nil {
+			return nil, from, batch.err
+		}
+		if lastOffset + 1 > next {
+			next = lastOffset + 1
+		}
+		for len(aborted) > 0 && aborted[0].firstOffset <= lastOffset {
+			abortedProducers[aborted[0].producerID] = true
+			aborted = aborted[1:]
+		}
+		if attributes & 0x20 != 0 { //a control batch marking the end of a transaction, which ends its abort too
+			delete(abortedProducers, producerID)
+			continue
+		}
+		if attributes & 0x10 != 0 && abortedProducers[producerID] { //a batch of an aborted transaction
+			continue
+		}
+		body := batch.data
+		switch attributes & 0x7 {
+		case 0:
+		case 1:
+			gz, err := gzip.NewReader(bytes.NewReader(body))
+			if err != nil {
+				return nil, from, err
+			}
+			if body, err = io.ReadAll(gz); err != nil {
+				return nil, from, err
+			}
+		default:
+			return nil, from, fmt.Errorf("compression codec %d is not supported, only none and gzip", attributes & 0x7)
+		}
+		record := &kafkaReader{data: body}
+		for i := int32(0); i < count; i++ {
+			record.varint() //length
+			record.int8() //attributes
+			record.varint() //timestamp delta
+			offset := baseOffset + record.varint()
+			if keyLength := record.varint(); keyLength > 0 {
+				record.next(int(keyLength))
+			}
+			var value []byte
+			if valueLength := record.varint(); valueLength >= 0 {
+				value = record.next(int(valueLength))
+			}
+			for headers := record.varint(); headers > 0; headers-- {
+				record.next(int(record.varint()))
+				if valueLength := record.varint(); valueLength > 0 {
+					record.next(int(valueLength))
+				}
+			}
+			if record.err != nil {
+				return nil, from, record.err
+			}
+			if offset >= from {
+				decoded = append(decoded, kafkaRecord{offset: offset, value: value})
+			}
+		}
+	}
+	return decoded, next, nil
+}
+
+// Encodes a record batch of one record whose value is payload and which has no key
+func encodeRecordBatch(payload []byte) []byte {
+	var record kafkaWriter
+	record.int8(0) //attributes
+	record.varint(0) //timestamp delta
+	record.varint(0) //offset delta
+	record.varint(-1) //null key
+	record.varint(int64(len(payload)))
+	record.Write(payload)
+	record.varint(0) //headers
+
+	now := time.Now().UnixMilli()
+	var checked kafkaWriter //the part of the batch its checksum covers
+	checked.int16(0) //attributes: uncompressed
+	checked.int32(0) //last offset delta
+	checked.int64(now)
+	checked.int64(now)
+	checked.int64(-1) //producer id
+	checked.int16(-1) //producer epoch
+	checked.int32(-1) //base sequence
+	checked.int32(1) //records
+	checked.varint(int64(record.Len()))
+	checked.Write(record.Bytes())
+
+	var batch kafkaWriter
+	batch.int64(0) //base offset, assigned by the broker
+	batch.int32(int32(4 + 1 + 4 + checked.Len()))
+	batch.int32(-1) //partition leader epoch
+	batch.int8(2) //magic
+	batch.int32(int32(crc32.Checksum(checked.Bytes(), castagnoli)))
+	batch.Write(checked.Bytes())
+	return batch.Bytes()
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"proj3/data"
+	"proj3/regression"
+)
+
+// A range test stops once the loss exceeds the lowest loss seen by this factor, as alpha has clearly diverged
+const lrTestDivergence = 4
+
+// Entry point of the lrtest subcommand: calibrate lrtest -i="filename.csv" -min=1e-4 -max=10 -epochs=100
+// Runs a single gradient descent whose alpha grows geometrically from -min to -max, one step per epoch, and prints the
+// loss after each step. The alpha with the lowest loss bounds the alpha grid from above, and a decade below it is a
+// reasonable lower bound, so one cheap run replaces guessing the grid
+func lrRangeTest(args []string) {
+	flags := flag.NewFlagSet("lrtest", flag.ExitOnError)
+	inpath := flags.String("i", "", "filepath of the input data csv file")
+	minAlpha := flags.Float64("min", 1e-4, "alpha of the first epoch")
+	maxAlpha := flags.Float64("max", 10, "alpha of the last epoch")
+	numEpochs := flags.Int("epochs", 100, "number of epochs, and so of alphas tried")
+	missing := flags.String("missing", "drop", "handling of missing values: drop or mean")
+	categorical := flags.String("categorical", "", "comma separated indexes of categorical csv columns")
+	scale := flags.String("scale", "minmax", "scaling of each independent column before training: minmax or standard")
+	flags.Parse(args)
+	if *inpath == "" {
+		fmt.Println("Usage: calibrate lrtest -i=\"filename.csv\" -min=1e-4 -max=10 -epochs=100")
+		os.Exit(0)
+	}
+	if *minAlpha <= 0 || *maxAlpha <= *minAlpha || *numEpochs < 2 {
+		fmt.Println("Error: lrtest needs 0 < -min < -max and at least 2 -epochs")
+		os.Exit(1)
+	}
+
+	trainingData := data.LoadTrainingData(*inpath, data.LoadOptions{Missing: *missing, Categorical: parseColumnIndexes(*categorical)})
+	dataNormalized := regression.Scale(trainingData, regression.FitScaling(trainingData, *scale))
+
+	parameters := regression.Parameters{0, 0}
+	growth := math.Pow(*maxAlpha / *minAlpha, 1 / float64(*numEpochs - 1))
+	alpha := *minAlpha
+	bestAlpha, bestLoss := math.NaN(), math.Inf(1)
+	fmt.Printf("%-6s %14s %20s\n", "epoch", "alpha", "loss")
+	for i := 0; i < *numEpochs; i++ {
+		parameters = regression.UpdateParams(parameters, dataNormalized, alpha)
+		loss := regression.CalcMSE(regression.Forecast(parameters.Mu, parameters.Beta, dataNormalized.X), dataNormalized.Y)
+		fmt.Printf("%-6d %14.6g %20.6f\n", i, alpha, loss)
+		if loss < bestLoss {
+			bestAlpha, bestLoss = alpha, loss
+		}
+		if math.IsNaN(loss) || loss > lrTestDivergence * bestLoss {
+			fmt.Printf("Diverged at alpha %.4g\n", alpha)
+			break
+		}
+		alpha *= growth
+	}
+	if math.IsNaN(bestAlpha) {
+		fmt.Println("Error: the loss was never finite, lower -min")
+		os.Exit(1)
+	}
+	fmt.Printf("Lowest loss %f at alpha %.4g\n", bestLoss, bestAlpha)
+	fmt.Printf("Suggested alpha grid bounds: %.4g to %.4g\n", bestAlpha / 10, bestAlpha)
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"proj3/data"
+	"proj3/gridsearch"
+	"proj3/regression"
+	"sync"
+	"sync/atomic"
+)
+
+// The result of training one permutation
+type cachedResult struct {
+	parameters regression.Parameters
+	mse float64
+	stats gridsearch.Stats
+}
+
+// Results of every permutation trained in a run, keyed by the fingerprint of the data it was trained on and the hash
+// of its configuration, so a permutation repeated by several tasks is trained once and its result reused. With a
+// directory the cache persists across runs: the results trained on each dataset are appended to a journal named by
+// its fingerprint, eg 6b61ca...8b11.jsonl, which is read the first time a task searches that dataset, so re-running
+// an expanded grid only trains the new permutations. Search goroutines use it concurrently, so it is guarded by a
+// lock. A nil cache reuses nothing
+type resultCache struct {
+	lock sync.Mutex
+	results map[string]cachedResult
+	hits atomic.Int64
+	dir string // "" for a cache of this run only
+	journals map[string]*os.File // open journal of every dataset read so far, by fingerprint
+}
+
+// Creates a cache, persisted into dir unless it is ""
+func newResultCache(dir string) *resultCache {
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			log.Fatal("Error: cannot create the cache directory ", dir, ": ", err)
+		}
+	}
+	return &resultCache{results: make(map[string]cachedResult), dir: dir, journals: make(map[string]*os.File)}
+}
+
+// One journal line of a persisted result. Metrics that are not finite, eg of a diverged permutation, are null and
+// read back as NaN
+type persistedResult struct {
+	Config string `json:"config"`
+	Beta *float64 `json:"beta"`
+	Mu *float64 `json:"mu"`
+	MSE *float64 `json:"mse"`
+	ConvergedEpoch int `json:"convergedEpoch"`
+	MaxGradientNorm *float64 `json:"maxGradientNorm"`
+	BestEpoch int `json:"bestEpoch"`
+	BestValidationMSE *float64 `json:"bestValMse"`
+	Epochs int `json:"epochs"`
+	TrainSeconds float64 `json:"trainSeconds"`
+}
+
+// Returns value, or NaN for nil
+func orNaN(value *float64) float64 {
+	if value == nil {
+		return math.NaN()
+	}
+	return *value
+}
+
+// Reads the journal of a dataset into the cache and opens it for appending, unless the cache is not persisted or the
+// journal is open already. A line cut short by a crash is skipped. Called with the lock held
+func (c *resultCache) openJournal(dataset string) {
+	if c.dir == "" || c.journals[dataset] != nil {
+		return
+	}
+	path := filepath.Join(c.dir, dataset + ".jsonl")
+	file, err := os.OpenFile(path, os.O_RDWR | os.O_CREATE | os.O_APPEND, 0644)
+	if err != nil {
+		log.Fatal("Error: cannot open the cache journal ", path, ": ", err)
+	}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64 * 1024), 1024 * 1024)
+	for scanner.Scan() {
+		var p persistedResult
+		if json.Unmarshal(scanner.Bytes(), &p) != nil {
+			continue
+		}
+		c.results[dataset + "/" + p.Config] = cachedResult{parameters: regression.Parameters{Beta: orNaN(p.Beta), Mu: orNaN(p.Mu)},
+			mse: orNaN(p.MSE), stats: gridsearch.Stats{ConvergedEpoch: p.ConvergedEpoch, MaxGradientNorm: orNaN(p.MaxGradientNorm),
+			BestEpoch: p.BestEpoch, BestValidationMSE: orNaN(p.BestValidationMSE), Epochs: p.Epochs, Seconds: p.TrainSeconds}}
+	}
+	if err := scanner.Err(); err != nil {
+		log.Fatal("Error: cannot read the cache journal ", path, ": ", err)
+	}
+	c.journals[dataset] = file
+}
+
+// Appends a result to the journal of its dataset. A journal that cannot be written is logged rather than failing the
+// search, which only loses the result for later runs. Called with the lock held
+func (c *resultCache) persist(dataset string, config string, result cachedResult) {
+	file := c.journals[dataset]
+	if file == nil {
+		return
+	}
+	line, err := json.Marshal(persistedResult{Config: config, Beta: finite(result.parameters.Beta), Mu: finite(result.parameters.Mu),
+		MSE: finite(result.mse), ConvergedEpoch: result.stats.ConvergedEpoch, MaxGradientNorm: finite(result.stats.MaxGradientNorm),
+		BestEpoch: result.stats.BestEpoch, BestValidationMSE: finite(result.stats.BestValidationMSE), Epochs: result.stats.Epochs,
+		TrainSeconds: result.stats.Seconds})
+	if err == nil {
+		_, err = file.Write(append(line, '\n'))
+	}
+	if err != nil {
+		log.Println("Warning: cannot write the cache journal", file.Name(), "-", err)
+	}
+}
+
+// Closes the journals of a persisted cache
+func (c *resultCache) Close() {
+	if c == nil {
+		return
+	}
+	for _, file := range c.journals {
+		file.Close()
+	}
+}
+
+// Returns the number of permutations whose result was reused. A nil cache reused none
+func (c *resultCache) reused() int64 {
+	if c == nil {
+		return 0
+	}
+	return c.hits.Load()
+}
+
+// The cache as seen by one task: the fingerprint of the task's data, and the settings of the run that change what
+// training a permutation gives
+type taskCache struct {
+	cache *resultCache
+	dataset string
+	settings string
+}
+
+// Returns the cache of a task searching on taskData, or nil for a nil cache
+func (c *resultCache) forTask(taskData data.InputData, opts searchOptions) *taskCache {
+	if c == nil {
+		return nil
+	}
+	validation := ""
+	if opts.validation != nil {
+		validation = data.Fingerprint(*opts.validation)
+	}
+	settings := fmt.Sprintf("scale=%s validation=%s every=%d restoreBest=%t trackGradient=%t", opts.scale, validation,
+		opts.validationEvery, opts.restoreBest, opts.detailLog != nil)
+	return c.forDataset(data.Fingerprint(taskData), settings)
+}
+
+// Returns the cache of a task searching the dataset of a fingerprint with the given settings, or nil for a nil cache
+func (c *resultCache) forDataset(dataset string, settings string) *taskCache {
+	if c == nil {
+		return nil
+	}
+	c.lock.Lock()
+	c.openJournal(dataset)
+	c.lock.Unlock()
+	return &taskCache{cache: c, dataset: dataset, settings: settings}
+}
+
+// Returns the hash of a permutation's configuration: every hyperparameter but the outpath, and the run's settings
+func (t *taskCache) configHash(hyperParams Hyperparameters) string {
+	hyperParams.Outpath = ""
+	hash := sha256.Sum256([]byte(fmt.Sprintf("%+v %s", hyperParams, t.settings)))
+	return hex.EncodeToString(hash[:])
+}
+
+// Returns the result of a permutation trained before on the task's data, if any
+func (t *taskCache) lookup(hyperParams Hyperparameters) (cachedResult, bool) {
+	if t == nil {
+		return cachedResult{}, false
+	}
+	key := t.dataset + "/" + t.configHash(hyperParams)
+	t.cache.lock.Lock()
+	result, ok := t.cache.results[key]
+	t.cache.lock.Unlock()
+	if ok {
+		t.cache.hits.Add(1)
+	}
+	return result, ok
+}
+
+// Stores the result of training a permutation on the task's data
+func (t *taskCache) store(hyperParams Hyperparameters, result cachedResult) {
+	if t == nil {
+		return
+	}
+	config := t.configHash(hyperParams)
+	t.cache.lock.Lock()
+	t.cache.results[t.dataset + "/" + config] = result
+	t.cache.persist(t.dataset, config, result)
+	t.cache.lock.Unlock()
+}
+
+// Splits a task's permutations for -skip-existing into those no earlier run trained, which are returned, and those one
+// did, of which the best is returned with its result. trained is false if no permutation was trained before
+func (t *taskCache) skipTrained(permutations []Hyperparameters) (remaining []Hyperparameters, best Hyperparameters,
+	result cachedResult, trained bool) {
+	result.mse = math.NaN()
+	for _, permutation := range permutations {
+		earlier, ok := t.lookup(permutation)
+		if !ok {
+			remaining = append(remaining, permutation)
+			continue
+		}
+		if !trained || earlier.mse < result.mse || math.IsNaN(result.mse) {
+			best, result = permutation, earlier
+		}
+		trained = true
+	}
+	return remaining, best, result, trained
+}
+
+// Whether the results file of a task exists, so -skip-existing can leave a task with nothing new to train alone
+func resultsExist(outpath string) bool {
+	_, err := os.Stat(outpath)
+	return err == nil
+}
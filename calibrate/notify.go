@@ -0,0 +1,241 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"net/smtp"
+	"os"
+	"proj3/gridsearch"
+	"proj3/regression"
+	"strings"
+	"time"
+)
+
+// How long a webhook may take to answer
+const notifyTimeout = 10 * time.Second
+
+// JSON summary of a finished task sent to the notification sinks. Hyperparameters holds the winner's searched dimensions by
+// their results column name; metrics that are not finite are null
+type taskSummary struct {
+	Task string `json:"task"`
+	Hyperparameters map[string]string `json:"hyperparameters"`
+	Beta *float64 `json:"beta"`
+	Mu *float64 `json:"mu"`
+	MSE *float64 `json:"mse"`
+	ConvergedEpoch *int `json:"convergedEpoch"`
+	TrainSeconds float64 `json:"trainSeconds"`
+	TaskSeconds float64 `json:"taskSeconds"`
+	DataFingerprint string `json:"dataFingerprint"`
+}
+
+// Builds the summary of a finished task from its winner
+func newTaskSummary(hyperParams Hyperparameters, parameters regression.Parameters, mse float64, stats gridsearch.Stats,
+	taskSeconds float64, fingerprint string) taskSummary {
+	summary := taskSummary{Task: hyperParams.Outpath, Hyperparameters: hyperparamMap(hyperParams), Beta: finite(parameters.Beta),
+		Mu: finite(parameters.Mu), MSE: finite(mse), TrainSeconds: stats.Seconds, TaskSeconds: taskSeconds, DataFingerprint: fingerprint}
+	if hyperParams.Optimizer == nil { //every permutation diverged or there were none, so there is no winner
+		summary.Beta, summary.Mu, summary.MSE = nil, nil, nil
+	}
+	if stats.ConvergedEpoch >= 0 {
+		summary.ConvergedEpoch = &stats.ConvergedEpoch
+	}
+	return summary
+}
+
+// Returns the searched hyperparameters of a permutation by their results column name, leaving out the NA ones
+func hyperparamMap(hyperParams Hyperparameters) map[string]string {
+	values := make(map[string]string)
+	for i, value := range hyperparamColumns(hyperParams) {
+		if value != "NA" {
+			values[hyperparamHeader[i]] = value
+		}
+	}
+	return values
+}
+
+// Returns a pointer to value, or nil if it is NaN or infinite, which JSON cannot encode
+func finite(value float64) *float64 {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return nil
+	}
+	return &value
+}
+
+// A sink operators hear about tasks from: every finished task, and the failed ones, whose permutations all diverged
+// or which had none to search
+type notifier interface {
+	taskFinished(summary taskSummary) error
+	taskFailed(summary taskSummary, reason string) error
+}
+
+// Notifies every sink of a finished task, or of its failure if it has no winner. A sink that fails is logged rather
+// than failing the search, whose results files are already written
+func notifyTask(notifiers []notifier, summary taskSummary, hasWinner bool) {
+	for _, n := range notifiers {
+		var err error
+		if hasWinner {
+			err = n.taskFinished(summary)
+		} else {
+			err = n.taskFailed(summary, regression.ErrDiverged.Error() + ": no permutation reached a finite MSE, or the task has none")
+		}
+		if err != nil {
+			log.Println("Warning: cannot notify of task", summary.Task, "-", err)
+		}
+	}
+}
+
+// Formats a one line message about a task for people, as Slack and email sinks send
+func summaryText(summary taskSummary) string {
+	text := fmt.Sprintf("calibrate task %s finished in %.1fs", summary.Task, summary.TaskSeconds)
+	if summary.MSE != nil {
+		text += fmt.Sprintf(": mse %g", *summary.MSE)
+	}
+	if summary.Beta != nil && summary.Mu != nil {
+		text += fmt.Sprintf(", beta %g, mu %g", *summary.Beta, *summary.Mu)
+	}
+	if optimizer, ok := summary.Hyperparameters["optimizer"]; ok {
+		text += ", optimizer " + optimizer
+	}
+	return text
+}
+
+// Prints notifications on stdout
+type stdoutNotifier struct{}
+
+func (stdoutNotifier) taskFinished(summary taskSummary) error {
+	fmt.Println("Notification:", summaryText(summary))
+	return nil
+}
+
+func (stdoutNotifier) taskFailed(summary taskSummary, reason string) error {
+	fmt.Println("Notification: calibrate task", summary.Task, "failed:", reason)
+	return nil
+}
+
+// Posts the JSON summary of every task to a webhook, with "failed" and "reason" added for failed tasks
+type webhookNotifier struct {
+	url string
+}
+
+func (w webhookNotifier) taskFinished(summary taskSummary) error {
+	return postJSON(w.url, summary)
+}
+
+func (w webhookNotifier) taskFailed(summary taskSummary, reason string) error {
+	return postJSON(w.url, struct {
+		taskSummary
+		Failed bool `json:"failed"`
+		Reason string `json:"reason"`
+	}{summary, true, reason})
+}
+
+// Posts messages to a Slack incoming webhook
+type slackNotifier struct {
+	url string
+}
+
+func (s slackNotifier) taskFinished(summary taskSummary) error {
+	return postJSON(s.url, map[string]string{"text": summaryText(summary)})
+}
+
+func (s slackNotifier) taskFailed(summary taskSummary, reason string) error {
+	return postJSON(s.url, map[string]string{"text": ":warning: calibrate task " + summary.Task + " failed: " + reason})
+}
+
+// Emails notifications through an SMTP server, authenticating with PLAIN auth if a username is configured. The
+// password is read from the CALIBRATE_SMTP_PASSWORD environment variable, so it stays out of config files
+type emailNotifier struct {
+	Addr string `json:"addr"` // host:port of the SMTP server
+	From string `json:"from"`
+	To []string `json:"to"`
+	Username string `json:"username"`
+	OnlyFailures bool `json:"onlyFailures"` // email failed tasks only, as every finished task can be a lot of mail
+}
+
+func (e emailNotifier) taskFinished(summary taskSummary) error {
+	if e.OnlyFailures {
+		return nil
+	}
+	return e.send("calibrate task finished: " + summary.Task, summaryText(summary))
+}
+
+func (e emailNotifier) taskFailed(summary taskSummary, reason string) error {
+	return e.send("calibrate task failed: " + summary.Task, "calibrate task " + summary.Task + " failed: " + reason)
+}
+
+// Sends one plain text email
+func (e emailNotifier) send(subject string, body string) error {
+	var auth smtp.Auth
+	if e.Username != "" {
+		auth = smtp.PlainAuth("", e.Username, os.Getenv("CALIBRATE_SMTP_PASSWORD"), strings.Split(e.Addr, ":")[0])
+	}
+	message := "From: " + e.From + "\r\nTo: " + strings.Join(e.To, ", ") + "\r\nSubject: " + subject + "\r\n\r\n" + body + "\r\n"
+	return smtp.SendMail(e.Addr, auth, e.From, e.To, []byte(message))
+}
+
+// Posts a JSON body to url
+func postJSON(url string, body interface{}) error {
+	encoded, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	client := &http.Client{Timeout: notifyTimeout}
+	response, err := client.Post(url, "application/json", bytes.NewReader(encoded))
+	if err != nil {
+		return err
+	}
+	response.Body.Close()
+	if response.StatusCode / 100 != 2 {
+		return fmt.Errorf("%s answered %s", url, response.Status)
+	}
+	return nil
+}
+
+// Notification sinks selected by a -notify-config JSON file, eg
+// {"stdout": true, "webhook": "https://host/hook", "slack": "https://hooks.slack.com/services/...",
+//  "email": {"addr": "smtp.host:587", "from": "calibrate@host", "to": ["ops@host"], "username": "calibrate"}}
+type notifyConfig struct {
+	Stdout bool `json:"stdout"`
+	Webhook string `json:"webhook"`
+	Slack string `json:"slack"`
+	Email *emailNotifier `json:"email"`
+}
+
+// Returns the notification sinks of a -notify-config file, if any, and of a -notify-url webhook, if any
+func loadNotifiers(configPath string, webhookURL string) []notifier {
+	var notifiers []notifier
+	if webhookURL != "" {
+		notifiers = append(notifiers, webhookNotifier{webhookURL})
+	}
+	if configPath == "" {
+		return notifiers
+	}
+	content, err := os.ReadFile(configPath)
+	if err != nil {
+		log.Fatal("Error: cannot read notification config ", configPath, ": ", err)
+	}
+	var config notifyConfig
+	if err := json.Unmarshal(content, &config); err != nil {
+		log.Fatal("Error: invalid notification config ", configPath, ": ", err)
+	}
+	if config.Stdout {
+		notifiers = append(notifiers, stdoutNotifier{})
+	}
+	if config.Webhook != "" {
+		notifiers = append(notifiers, webhookNotifier{config.Webhook})
+	}
+	if config.Slack != "" {
+		notifiers = append(notifiers, slackNotifier{config.Slack})
+	}
+	if config.Email != nil {
+		if config.Email.Addr == "" || config.Email.From == "" || len(config.Email.To) == 0 {
+			log.Fatal("Error: the email notifier of ", configPath, " needs addr, from and to")
+		}
+		notifiers = append(notifiers, *config.Email)
+	}
+	return notifiers
+}
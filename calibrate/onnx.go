@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"proj3/gridsearch"
+	"proj3/regression"
+	"strings"
+)
+
+// Versions of the ONNX format and operator set the exported graphs use, which every current inference runtime loads
+const (
+	onnxIRVersion = 7
+	onnxOpset = 13
+)
+
+// ONNX tensor element type of float32
+const onnxFloat = 1
+
+// Writes the winning model as an ONNX graph next to the results file, eg results.onnx for results.csv, so inference
+// runtimes such as onnxruntime serve it. The graph takes x as a float tensor of shape [N, 1] and outputs y of the same
+// shape: x is scaled as in training, the scaled coefficients applied, then the inverse link of a generalized linear
+// model and the inverse target transform. Inverse Box-Cox bases below 0 are clipped to 0, as in training
+func writeONNX(outpath string, parameters regression.Parameters, scaling regression.ColumnScale, hyperParams Hyperparameters,
+	policy writePolicy) {
+	graph := onnxGraph{}
+	graph.node("Sub", "x", graph.constant("offset", scaling.Offset))
+	graph.node("Div", graph.last, graph.constant("scale", scaling.Scale))
+	graph.node("Mul", graph.last, graph.constant("beta", parameters.Beta * scaling.Scale))
+	graph.node("Add", graph.last, graph.constant("mu", parameters.Mu + parameters.Beta * scaling.Offset))
+	if hyperParams.Loss != nil && gridsearch.IsGLM(hyperParams.Loss[0]) {
+		switch hyperParams.Link[0] {
+		case "log":
+			graph.node("Exp", graph.last)
+		case "inverse":
+			graph.node("Reciprocal", graph.last)
+		case "identity":
+		default:
+			log.Fatal("Error: cannot export the ", hyperParams.Link[0], " link of ", outpath, " to ONNX")
+		}
+	}
+	if hyperParams.Target != nil && hyperParams.Target[0] == "log" {
+		graph.node("Exp", graph.last)
+	} else if hyperParams.Target != nil && hyperParams.Target[0] == "boxcox" {
+		lambda := hyperParams.BoxCox[0]
+		if lambda == 0 {
+			graph.node("Exp", graph.last)
+		} else {
+			graph.node("Mul", graph.last, graph.constant("boxcoxLambda", lambda))
+			graph.node("Add", graph.last, graph.constant("one", 1))
+			graph.node("Relu", graph.last)
+			graph.node("Pow", graph.last, graph.constant("boxcoxInverseLambda", 1 / lambda))
+		}
+	}
+	graph.node("Identity", graph.last)
+	graph.nodes[len(graph.nodes) - 1].output = "y"
+
+	extension := outpathExtension(outpath)
+	policy.write(strings.TrimSuffix(outpath, extension) + ".onnx", graph.encode(filepath.Base(outpath)))
+}
+
+// An ONNX graph under construction: a chain of nodes, each reading the previous one's output and constants
+type onnxGraph struct {
+	nodes []onnxNode
+	constants []onnxConstant
+	last string // output of the last node added
+}
+
+type onnxNode struct {
+	opType string
+	inputs []string
+	output string
+}
+
+// A scalar initializer, which ONNX broadcasts against the [N, 1] tensors
+type onnxConstant struct {
+	name string
+	value float32
+}
+
+// Adds a scalar constant and returns its name
+func (g *onnxGraph) constant(name string, value float64) string {
+	g.constants = append(g.constants, onnxConstant{name, float32(value)})
+	return name
+}
+
+// Appends a node of opType reading inputs, whose output becomes last
+func (g *onnxGraph) node(opType string, inputs ...string) {
+	output := fmt.Sprintf("%s_%d", strings.ToLower(opType), len(g.nodes))
+	g.nodes = append(g.nodes, onnxNode{opType, inputs, output})
+	g.last = output
+}
+
+// Encodes the graph as a ModelProto
+func (g *onnxGraph) encode(name string) []byte {
+	var graph protoMessage
+	for _, n := range g.nodes {
+		var node protoMessage
+		node.strs(1, n.inputs)
+		node.str(2, n.output)
+		node.str(3, n.output)
+		node.str(4, n.opType)
+		graph.message(1, &node)
+	}
+	graph.str(2, name)
+	for _, c := range g.constants {
+		var tensor protoMessage
+		tensor.integer(2, onnxFloat)
+		tensor.floats(4, []float32{c.value})
+		tensor.str(8, c.name)
+		graph.message(5, &tensor)
+	}
+	graph.message(11, onnxTensorInfo("x"))
+	graph.message(12, onnxTensorInfo("y"))
+
+	var opset protoMessage
+	opset.integer(2, onnxOpset)
+	var model protoMessage
+	model.integer(1, onnxIRVersion)
+	model.str(2, "calibrate")
+	model.message(7, &graph)
+	model.message(8, &opset)
+	return model.Bytes()
+}
+
+// Returns the ValueInfoProto of a float tensor of shape [N, 1]
+func onnxTensorInfo(name string) *protoMessage {
+	var rows, columns protoMessage
+	rows.str(2, "N")
+	columns.integer(1, 1)
+	var shape protoMessage
+	shape.message(1, &rows)
+	shape.message(1, &columns)
+	var tensor protoMessage
+	tensor.integer(1, onnxFloat)
+	tensor.message(2, &shape)
+	var typ protoMessage
+	typ.message(1, &tensor)
+	var info protoMessage
+	info.str(1, name)
+	info.message(2, &typ)
+	return &info
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"math/rand"
+	"proj3/data"
+	"proj3/gridsearch"
+	"proj3/regression"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Training data of an -out-of-core search, read from its file in chunks on every pass rather than loaded, so data
+// larger than memory can be searched. Only x and y are read, as feature columns would need the rows in memory for the
+// design matrix
+type outOfCoreData struct {
+	path string
+	chunkRows int
+	rows int
+	scaling regression.Scaling // of x by the -scale method
+	sample data.InputData // uniform sample of at most chunkRows rows, which the baseline and GLM starting points are fit on
+}
+
+// Opens the training data file at path for an out-of-core search, reading it once to fit the scaling of x by the scale
+// method and draw its sample, seeded by seed
+func newOutOfCoreData(path string, chunkRows int, scale string, seed int64) *outOfCoreData {
+	d := &outOfCoreData{path: path, chunkRows: chunkRows}
+	minX, maxX, mean, m2 := math.Inf(1), math.Inf(-1), 0.0, 0.0
+	rng := gridsearch.SeedRandom(seed)
+	d.pass(func(chunk data.InputData) {
+		for i, x := range chunk.X {
+			d.rows++
+			minX, maxX = math.Min(minX, x), math.Max(maxX, x)
+			delta := x - mean
+			mean += delta / float64(d.rows)
+			m2 += delta * (x - mean)
+			if len(d.sample.X) < chunkRows {
+				d.sample.X, d.sample.Y = append(d.sample.X, x), append(d.sample.Y, chunk.Y[i])
+			} else if j := rng.Intn(d.rows); j < chunkRows {
+				d.sample.X[j], d.sample.Y[j] = x, chunk.Y[i]
+			}
+		}
+	})
+	if d.rows == 0 {
+		log.Fatal("Error: ", path, " holds no rows with both x and y")
+	}
+	if scale == "standard" {
+		std := 1.0
+		if d.rows > 1 && m2 > 0 {
+			std = math.Sqrt(m2 / float64(d.rows - 1))
+		}
+		d.scaling = regression.Scaling{X: regression.ColumnScale{Offset: mean, Scale: std}}
+	} else {
+		xRange := maxX - minX
+		if xRange == 0 {
+			xRange = 1
+		}
+		d.scaling = regression.Scaling{X: regression.ColumnScale{Offset: minX, Scale: xRange}}
+	}
+	return d
+}
+
+// Reads the file once, calling fn with each chunk
+func (d *outOfCoreData) pass(fn func(chunk data.InputData)) {
+	data.ReadChunks(d.path, d.chunkRows, fn)
+}
+
+// Returns the MSE of a linear fit over every row of the file
+func (d *outOfCoreData) mse(parameters regression.Parameters) float64 {
+	sse := 0.0
+	d.pass(func(chunk data.InputData) {
+		sse += regression.CalcMSE(regression.Forecast(parameters.Mu, parameters.Beta, chunk.X), chunk.Y) * float64(len(chunk.X))
+	})
+	return sse / float64(d.rows)
+}
+
+// Returns why a permutation cannot be trained out of core, or "" if it can. Each chunk is a step of gd or nag, or a
+// run of mini-batch steps; the other optimizers, the plateau schedule and the tolerance need the full data every epoch
+func outOfCoreUnsupported(hyperParams Hyperparameters) string {
+	switch {
+	case hyperParams.Optimizer[0] != "gd" && hyperParams.Optimizer[0] != "nag":
+		return "optimizer " + hyperParams.Optimizer[0] + " needs the data in memory, -out-of-core trains gd and nag only"
+	case hyperParams.Schedule != nil && hyperParams.Schedule[0] == "plateau":
+		return "the plateau schedule needs the data in memory"
+	case hyperParams.Tolerance != nil:
+		return "a tolerance needs the data in memory"
+	}
+	return ""
+}
+
+// A permutation trained out of core, advanced one chunk at a time
+type streamedRun struct {
+	hyperParams Hyperparameters
+	scaling regression.Scaling
+	gradient regression.GradientFunc
+	parameters regression.Parameters // on the scaled data while training, on the original scale once trained
+	velocity regression.Parameters // of nag
+	rng *rand.Rand // shuffles the mini-batches of every chunk
+	epochs int
+	sse float64 // squared error over the rows scored so far
+	seconds float64
+}
+
+// Creates the run of a permutation, starting from the parameters gridsearch.InitialParameters gives on the file's sample
+func newStreamedRun(d *outOfCoreData, hyperParams Hyperparameters) *streamedRun {
+	scaling := d.scaling
+	if hyperParams.NoIntercept || hyperParams.NonNegative == "all" { //as gridsearch.FitScaling, on the sample's scaling
+		scaling = scaling.Uncentered()
+	}
+	sample := trainingTarget(regression.Scale(d.sample, scaling), hyperParams)
+	return &streamedRun{hyperParams: hyperParams, scaling: scaling, gradient: gridsearch.LossGradient(hyperParams.Grid),
+		parameters: gridsearch.InitialParameters(sample, hyperParams.Grid), rng: gridsearch.SeedRandom(hyperParams.Seed)}
+}
+
+// Trains the run on one chunk in the given epoch: one step on the whole chunk, or a step per mini-batch of it
+func (r *streamedRun) step(chunk data.InputData, epoch int) {
+	start := time.Now()
+	normalized := trainingTarget(regression.Scale(chunk, r.scaling), r.hyperParams)
+	rows, size := normalized.All(), len(normalized.X)
+	if r.hyperParams.MiniBatchSize != nil && int(r.hyperParams.MiniBatchSize[0]) < size {
+		size = int(math.Max(1, r.hyperParams.MiniBatchSize[0]))
+		rows.Permute(r.rng)
+	}
+	alpha := gridsearch.ScheduledAlpha(r.hyperParams.Grid, epoch)
+	for _, rows := range rows.Batches(size) {
+		if r.hyperParams.Optimizer[0] == "nag" {
+			r.parameters, r.velocity = regression.UpdateParamsNesterovRows(r.parameters, r.velocity, normalized, rows, alpha,
+				r.hyperParams.Momentum[0], r.gradient)
+		} else {
+			r.parameters = regression.UpdateParamsRows(r.parameters, normalized, rows, alpha, r.gradient)
+		}
+		r.parameters = gridsearch.Constrain(r.parameters, r.hyperParams.Grid)
+	}
+	r.epochs = epoch + 1
+	r.seconds += time.Since(start).Seconds()
+}
+
+// Adds the squared error of the trained run on one chunk
+func (r *streamedRun) score(chunk data.InputData) {
+	for i, predicted := range gridsearch.Forecast(r.parameters, chunk.X, r.hyperParams.Grid) {
+		r.sse += (predicted - chunk.Y[i]) * (predicted - chunk.Y[i])
+	}
+}
+
+// Trains the runs of a task together, every pass over the file stepping each run still short of its numEpochs on
+// every chunk, so the file is read once per epoch of the longest run rather than once per epoch of every run. A last
+// pass scores the trained runs by MSE. With numThreads the runs are spread over that many goroutines per chunk
+func trainOutOfCore(d *outOfCoreData, runs []*streamedRun, numThreads int) {
+	if len(runs) == 0 {
+		return
+	}
+	maxEpochs := 0
+	for _, run := range runs {
+		maxEpochs = int(math.Max(float64(maxEpochs), run.hyperParams.NumEpochs[0]))
+	}
+	for epoch := 0; epoch < maxEpochs; epoch++ {
+		d.pass(func(chunk data.InputData) {
+			forEachRun(runs, numThreads, func(run *streamedRun) {
+				if epoch < int(run.hyperParams.NumEpochs[0]) {
+					run.step(chunk, epoch)
+				}
+			})
+		})
+	}
+	for _, run := range runs {
+		run.parameters = regression.UnScale(run.parameters, run.scaling)
+	}
+	d.pass(func(chunk data.InputData) {
+		forEachRun(runs, numThreads, func(run *streamedRun) { run.score(chunk) })
+	})
+}
+
+// Calls fn on every run, spreading the runs over numThreads goroutines, or on this one without threads
+func forEachRun(runs []*streamedRun, numThreads int, fn func(run *streamedRun)) {
+	if numThreads <= 1 {
+		for _, run := range runs {
+			fn(run)
+		}
+		return
+	}
+	var group sync.WaitGroup
+	for i := 0; i < numThreads && i < len(runs); i++ {
+		group.Add(1)
+		go func(first int) {
+			defer group.Done()
+			for j := first; j < len(runs); j += numThreads {
+				fn(runs[j])
+			}
+		}(i)
+	}
+	group.Wait()
+}
+
+// Grid search of -out-of-core mode, on data read from its file in chunks. Tasks are searched one after the other,
+// with each task's permutations trained together by trainOutOfCore. Statistics of the winner that need the rows in
+// memory, its standard errors and residuals, are written as NA
+func gridSearchOutOfCore(d *outOfCoreData, tasks io.Reader, numThreads int, opts searchOptions) {
+	settings := fmt.Sprintf("scale=%s outOfCore chunkRows=%d", opts.scale, d.chunkRows)
+	cache := opts.cache.forDataset(opts.fingerprint, settings)
+	for _, hyperParams := range readJSONInputTasks(tasks) {
+		hyperParams.Outpath = opts.shard.outpath(hyperParams.Outpath)
+		taskStart := time.Now()
+		taskSpan := opts.span.child("task")
+		taskSpan.set("outpath", hyperParams.Outpath)
+		if opts.journal.finishedBefore(hyperParams.Outpath) {
+			fmt.Fprintln(humanOutput(opts.machine), "Skipping", hyperParams.Outpath, "- it finished before the run was resumed")
+			opts.audit.skipped(hyperParams.Outpath, "it finished before the run was resumed")
+			taskSpan.end()
+			continue
+		}
+		optimalHyperParams := Hyperparameters{Outpath: hyperParams.Outpath}
+		optimalMSE := math.MaxFloat64
+		optimalModelParams := regression.Parameters{0, 0}
+		optimalStats := gridsearch.Stats{ConvergedEpoch: -1, BestEpoch: -1}
+
+		permutations := opts.shard.take(createArrayParamPermutations(hyperParams))
+		for _, permutation := range permutations {
+			if reason := outOfCoreUnsupported(permutation); reason != "" {
+				log.Fatal("Error: task ", hyperParams.Outpath, " cannot be searched out of core: ", reason)
+			}
+		}
+		if opts.skipExisting {
+			earlierBest, earlier, trained := Hyperparameters{}, cachedResult{}, false
+			permutations, earlierBest, earlier, trained = cache.skipTrained(permutations)
+			if trained && len(permutations) == 0 && resultsExist(hyperParams.Outpath) {
+				fmt.Fprintln(humanOutput(opts.machine), "Skipping", hyperParams.Outpath, "- earlier runs trained its whole grid")
+				opts.audit.skipped(hyperParams.Outpath, "earlier runs trained its whole grid")
+				taskSpan.end()
+				continue
+			}
+			if trained && earlier.mse < optimalMSE {
+				optimalHyperParams, optimalMSE, optimalModelParams, optimalStats = earlierBest, earlier.mse, earlier.parameters, earlier.stats
+			}
+		}
+		opts.audit.started(hyperParams.Outpath, len(permutations))
+		opts.progress.start(hyperParams.Outpath, len(permutations))
+		opts.dashboard.start(hyperParams.Outpath, len(permutations))
+
+		hooks := taskHooks(hyperParams.Outpath, opts)
+		results := make([]cachedResult, len(permutations))
+		trained := make([]bool, len(permutations))
+		runs := make([]*streamedRun, 0)
+		for i, permutation := range permutations {
+			if results[i], trained[i] = cache.lookup(permutation); !trained[i] {
+				runs = append(runs, newStreamedRun(d, permutation))
+			}
+		}
+		trainSpan := taskSpan.child("train")
+		trainSpan.set("permutations", strconv.Itoa(len(runs)))
+		trainOutOfCore(d, runs, numThreads)
+		trainSpan.end()
+		for i, permutation := range permutations {
+			if !trained[i] {
+				run := runs[0]
+				runs = runs[1:]
+				results[i] = cachedResult{parameters: run.parameters, mse: run.sse / float64(d.rows), stats: gridsearch.Stats{
+					ConvergedEpoch: -1, MaxGradientNorm: math.NaN(), BestEpoch: -1, BestValidationMSE: math.NaN(),
+					Epochs: run.epochs, Seconds: run.seconds}}
+				cache.store(permutation, results[i])
+			}
+			parameters, mse, stats := results[i].parameters, results[i].mse, results[i].stats
+			hooks.OnConfigDone(gridsearch.Result{Beta: parameters.Beta, Mu: parameters.Mu, MSE: mse, Metric: "mse", Score: mse,
+				Evaluated: 1, Permutation: permutation.Grid, Stats: stats})
+			if mse < optimalMSE {
+				optimalHyperParams, optimalMSE, optimalModelParams, optimalStats = permutation, mse, parameters, stats
+			}
+		}
+		writeSpan := taskSpan.child("write results")
+		writer(optimalHyperParams, optimalModelParams, optimalMSE, optimalStats, time.Since(taskStart).Seconds(), data.InputData{},
+			nil, opts)
+		writeSpan.end()
+		taskSpan.end()
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"path/filepath"
+	"strings"
+)
+
+// Reads every task from input up front, validates it, recording both into the audit log, and checks that no two
+// target the same outpath, as the later task's writer
+// would silently overwrite the earlier one's results. With the "error" policy the run stops before searching
+// anything; with "uniquify" later duplicates are renamed results_2.csv, results_3.csv, ... and the renames logged;
+// with "merge" they are kept, and the outpaths shared by several tasks are returned to be merged into one file.
+// Returns the tasks, in order, for the search to decode
+func checkOutpaths(input io.Reader, policy string, audit *auditLog) (io.Reader, map[string]bool) {
+	var tasks []jsonInput
+	dec := json.NewDecoder(input)
+	for {
+		var j jsonInput
+		err := dec.Decode(&j)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			log.Fatal("Error: cannot decode task ", len(tasks) + 1, ": ", err)
+		}
+		audit.received(len(tasks) + 1, j)
+		audit.validated(len(tasks) + 1, j.Outpath, len(createArrayParamPermutations(jsonToHyperparameters(j))))
+		tasks = append(tasks, j)
+	}
+
+	taken := make(map[string]bool)
+	for _, j := range tasks {
+		taken[filepath.Clean(j.Outpath)] = true
+	}
+	first := make(map[string]int)
+	shared := make(map[string]bool)
+	var encoded bytes.Buffer
+	enc := json.NewEncoder(&encoded)
+	for i := range tasks {
+		outpath := filepath.Clean(tasks[i].Outpath)
+		if earlier, ok := first[outpath]; ok && policy == "merge" {
+			shared[outpath] = true
+		} else if ok {
+			if policy != "uniquify" {
+				log.Fatal("Error: tasks ", earlier + 1, " and ", i + 1, " both write ", tasks[i].Outpath,
+					"; give them distinct outpaths or run with -duplicate-outpaths=uniquify or merge")
+			}
+			renamed := uniqueOutpath(tasks[i].Outpath, taken)
+			log.Println("Warning: task", i + 1, "writes", tasks[i].Outpath, "like task", earlier + 1, "- writing", renamed, "instead")
+			tasks[i].Outpath = renamed
+			outpath = filepath.Clean(renamed)
+			taken[outpath] = true
+		}
+		if _, ok := first[outpath]; !ok {
+			first[outpath] = i
+		}
+		enc.Encode(tasks[i])
+	}
+	return &encoded, shared
+}
+
+// Returns the first of outpath_2, outpath_3, ..., before the extension, that no task takes
+func uniqueOutpath(outpath string, taken map[string]bool) string {
+	extension := outpathExtension(outpath)
+	for n := 2; ; n++ {
+		candidate := fmt.Sprintf("%s_%d%s", strings.TrimSuffix(outpath, extension), n, extension)
+		if !taken[filepath.Clean(candidate)] {
+			return candidate
+		}
+	}
+}
+
+// Results files shared by several tasks under -duplicate-outpaths=merge. A single goroutine owns the rows of every
+// shared file and rewrites the file with one row per finished task, in the order they finish, each time a task adds
+// its row, so the file is always complete up to the last finished task
+type mergedResults struct {
+	shared map[string]bool
+	rows chan mergedRow
+}
+
+// A results row sent to the merging goroutine, which answers on written once the file holds it
+type mergedRow struct {
+	outpath string
+	header []string
+	row []string
+	written chan bool
+}
+
+// Starts the merging goroutine of the shared outpaths, writing their files by policy. Returns nil, which merges
+// nothing, if no outpath is shared
+func newMergedResults(shared map[string]bool, policy writePolicy) *mergedResults {
+	if len(shared) == 0 {
+		return nil
+	}
+	m := &mergedResults{shared: shared, rows: make(chan mergedRow)}
+	go func() {
+		files := make(map[string][][]string)
+		for r := range m.rows {
+			outpath := filepath.Clean(r.outpath)
+			if files[outpath] == nil {
+				files[outpath] = [][]string{r.header}
+			}
+			files[outpath] = append(files[outpath], r.row)
+			policy.writeCSV(outpath, files[outpath])
+			r.written <- true
+		}
+	}()
+	return m
+}
+
+// Whether outpath is shared by several tasks, so their rows are merged
+func (m *mergedResults) merges(outpath string) bool {
+	return m != nil && m.shared[filepath.Clean(outpath)]
+}
+
+// Adds a task's row to its shared results file and waits until the file is written
+func (m *mergedResults) write(outpath string, header []string, row []string) {
+	written := make(chan bool)
+	m.rows <- mergedRow{outpath: outpath, header: header, row: row, written: written}
+	<- written
+}
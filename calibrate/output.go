@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/csv"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"proj3/data"
+	"strings"
+	"time"
+)
+
+// How output files are written: atomically, through a temporary file renamed into place once complete, so a crash
+// never leaves a partial file behind. A failed write, as network filesystems occasionally have, is retried with a
+// backoff doubling from backoff, and once every retry has failed the file is spilled into spillDir on a local disk
+// instead, so the search's results are not lost. Without a spill directory the run stops as before
+type writePolicy struct {
+	retries int
+	backoff time.Duration
+	spillDir string
+}
+
+// Writes rows as a csv file at path, gzip compressed if it ends in .gz, following the policy, and returns the path
+// the file was written to
+func (p writePolicy) writeCSV(path string, rows [][]string) string {
+	var content bytes.Buffer
+	var w io.Writer = &content
+	var gzipWriter *gzip.Writer
+	if strings.HasSuffix(path, ".gz") {
+		gzipWriter = gzip.NewWriter(&content)
+		w = gzipWriter
+	}
+	writer := csv.NewWriter(w)
+	writer.WriteAll(rows)
+	err := writer.Error()
+	if err == nil && gzipWriter != nil {
+		err = gzipWriter.Close()
+	}
+	if err != nil {
+		log.Fatal("Error: cannot encode ", path, ": ", err)
+	}
+	return p.write(path, content.Bytes())
+}
+
+// Returns the extension of an outpath, counting a .gz suffix together with the extension before it, eg .csv.gz, so
+// files named after a compressed results file keep its base name
+func outpathExtension(outpath string) string {
+	extension := filepath.Ext(outpath)
+	if extension == ".gz" {
+		extension = filepath.Ext(strings.TrimSuffix(outpath, extension)) + extension
+	}
+	return extension
+}
+
+// Opens a results file for reading, decompressing it if it ends in .gz. The returned function closes it
+func openResults(path string) (io.Reader, func(), error) {
+	file, err := os.Open(path)
+	if err != nil || !strings.HasSuffix(path, ".gz") {
+		return file, func() { file.Close() }, err
+	}
+	gzipReader, err := gzip.NewReader(file)
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	return gzipReader, func() {
+		gzipReader.Close()
+		file.Close()
+	}, nil
+}
+
+// Returns where a file that cannot be written at path is spilled: its absolute path mirrored under spillDir, so
+// outpaths of the same name in different directories, eg /a/results.csv and /b/results.csv, do not replace each other
+func spillFilePath(spillDir string, path string) string {
+	if absolute, err := filepath.Abs(path); err == nil {
+		path = absolute
+	}
+	return filepath.Join(spillDir, strings.TrimPrefix(path, filepath.VolumeName(path)))
+}
+
+// Writes content at path, following the policy, and returns the path the file was written to
+func (p writePolicy) write(path string, content []byte) string {
+	backoff := p.backoff
+	err := data.WriteFileAtomic(path, content)
+	for attempt := 1; err != nil && attempt <= p.retries; attempt++ {
+		log.Printf("Warning: cannot write %s (%v), retry %d of %d in %v", path, err, attempt, p.retries, backoff)
+		time.Sleep(backoff)
+		backoff *= 2
+		err = data.WriteFileAtomic(path, content)
+	}
+	if err == nil {
+		return path
+	}
+	if p.spillDir == "" {
+		log.Fatal("Error: cannot write ", path, ": ", err)
+	}
+	spillPath := spillFilePath(p.spillDir, path)
+	if spillErr := os.MkdirAll(filepath.Dir(spillPath), 0755); spillErr != nil {
+		log.Fatal("Error: cannot write ", path, ": ", err, ", nor create the spill directory: ", spillErr)
+	}
+	if spillErr := data.WriteFileAtomic(spillPath, content); spillErr != nil {
+		log.Fatal("Error: cannot write ", path, ": ", err, ", nor spill it to ", spillPath, ": ", spillErr)
+	}
+	log.Println("Warning: cannot write", path, "-", err, "- spilled it to", spillPath)
+	return spillPath
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"log"
+	"path/filepath"
+	"proj3/gridsearch"
+	"proj3/regression"
+	"strings"
+)
+
+// A PMML 4.4 document holding a single regression model of y on x
+type pmmlDocument struct {
+	XMLName xml.Name `xml:"http://www.dmg.org/PMML-4_4 PMML"`
+	Version string `xml:"version,attr"`
+	Header pmmlHeader
+	DataDictionary pmmlDataDictionary
+	RegressionModel pmmlRegressionModel
+}
+
+type pmmlHeader struct {
+	Description string `xml:"description,attr"`
+	Application struct {
+		Name string `xml:"name,attr"`
+	}
+}
+
+type pmmlDataDictionary struct {
+	NumberOfFields int `xml:"numberOfFields,attr"`
+	DataFields []pmmlDataField `xml:"DataField"`
+}
+
+type pmmlDataField struct {
+	Name string `xml:"name,attr"`
+	Optype string `xml:"optype,attr"`
+	DataType string `xml:"dataType,attr"`
+}
+
+type pmmlRegressionModel struct {
+	ModelName string `xml:"modelName,attr"`
+	FunctionName string `xml:"functionName,attr"`
+	NormalizationMethod string `xml:"normalizationMethod,attr,omitempty"`
+	MiningFields []pmmlMiningField `xml:"MiningSchema>MiningField"`
+	Output *pmmlOutput
+	RegressionTable struct {
+		Intercept float64 `xml:"intercept,attr"`
+		NumericPredictor struct {
+			Name string `xml:"name,attr"`
+			Coefficient float64 `xml:"coefficient,attr"`
+		}
+	}
+}
+
+type pmmlMiningField struct {
+	Name string `xml:"name,attr"`
+	UsageType string `xml:"usageType,attr,omitempty"`
+}
+
+type pmmlOutput struct {
+	OutputFields []pmmlOutputField `xml:"OutputField"`
+}
+
+type pmmlOutputField struct {
+	Name string `xml:"name,attr"`
+	Optype string `xml:"optype,attr"`
+	DataType string `xml:"dataType,attr"`
+	Feature string `xml:"feature,attr"`
+	Expression interface{}
+}
+
+// Expressions of PMML: a built-in function applied to arguments, a field's value and a constant
+type pmmlApply struct {
+	XMLName xml.Name `xml:"Apply"`
+	Function string `xml:"function,attr"`
+	Args []interface{}
+}
+
+type pmmlFieldRef struct {
+	XMLName xml.Name `xml:"FieldRef"`
+	Field string `xml:"field,attr"`
+}
+
+type pmmlConstant struct {
+	XMLName xml.Name `xml:"Constant"`
+	DataType string `xml:"dataType,attr"`
+	Value float64 `xml:",chardata"`
+}
+
+func pmmlNumber(value float64) pmmlConstant {
+	return pmmlConstant{DataType: "double", Value: value}
+}
+
+// Writes the winning model as a PMML RegressionModel next to the results file, eg results.pmml for results.csv, for
+// scoring systems that only consume PMML. The regression table holds mu and beta on the original scale of x. A
+// single exp, from a log link or a log target, is the model's normalization method; other inverse links and target
+// transforms are applied by a "predicted_y" output field to the "linearPredictor" output field, clipping inverse
+// Box-Cox bases below 0 to 0 as in training
+func writePMML(outpath string, parameters regression.Parameters, hyperParams Hyperparameters, policy writePolicy) {
+	name := strings.TrimSuffix(filepath.Base(outpath), outpathExtension(outpath))
+	model := pmmlRegressionModel{ModelName: name, FunctionName: "regression",
+		MiningFields: []pmmlMiningField{{Name: "x"}, {Name: "y", UsageType: "target"}}}
+	model.RegressionTable.Intercept = parameters.Mu
+	model.RegressionTable.NumericPredictor.Name = "x"
+	model.RegressionTable.NumericPredictor.Coefficient = parameters.Beta
+
+	//the inverse link and target transform, applied in turn to the linear predictor
+	var inverses []string
+	if hyperParams.Loss != nil && gridsearch.IsGLM(hyperParams.Loss[0]) {
+		switch hyperParams.Link[0] {
+		case "log":
+			inverses = append(inverses, "exp")
+		case "inverse":
+			inverses = append(inverses, "reciprocal")
+		case "identity":
+		default:
+			log.Fatal("Error: cannot export the ", hyperParams.Link[0], " link of ", outpath, " to PMML")
+		}
+	}
+	lambda := 0.0
+	if hyperParams.Target != nil && hyperParams.Target[0] == "boxcox" {
+		lambda = hyperParams.BoxCox[0]
+	}
+	if _, ok := gridsearch.TargetTransform(hyperParams.Grid); ok && lambda == 0 {
+		inverses = append(inverses, "exp")
+	} else if ok {
+		inverses = append(inverses, "boxcox")
+	}
+	if len(inverses) == 1 && inverses[0] == "exp" {
+		model.NormalizationMethod = "exp"
+	} else if len(inverses) > 0 {
+		var expression interface{} = pmmlFieldRef{Field: "linearPredictor"}
+		for _, inverse := range inverses {
+			switch inverse {
+			case "exp":
+				expression = pmmlApply{Function: "exp", Args: []interface{}{expression}}
+			case "reciprocal":
+				expression = pmmlApply{Function: "/", Args: []interface{}{pmmlNumber(1), expression}}
+			case "boxcox":
+				base := pmmlApply{Function: "+", Args: []interface{}{pmmlApply{Function: "*", Args: []interface{}{pmmlNumber(lambda), expression}},
+					pmmlNumber(1)}}
+				clipped := pmmlApply{Function: "max", Args: []interface{}{base, pmmlNumber(0)}}
+				expression = pmmlApply{Function: "pow", Args: []interface{}{clipped, pmmlNumber(1 / lambda)}}
+			}
+		}
+		model.Output = &pmmlOutput{[]pmmlOutputField{
+			{Name: "linearPredictor", Optype: "continuous", DataType: "double", Feature: "predictedValue"},
+			{Name: "predicted_y", Optype: "continuous", DataType: "double", Feature: "transformedValue", Expression: expression}}}
+	}
+
+	document := pmmlDocument{Version: "4.4", DataDictionary: pmmlDataDictionary{NumberOfFields: 2,
+		DataFields: []pmmlDataField{{"x", "continuous", "double"}, {"y", "continuous", "double"}}}, RegressionModel: model}
+	document.Header.Description = fmt.Sprint("Winning model of ", outpath)
+	document.Header.Application.Name = "calibrate"
+	content, err := xml.MarshalIndent(document, "", "\t")
+	if err != nil {
+		log.Fatal("Error: cannot encode the PMML model of ", outpath, ": ", err)
+	}
+	policy.write(strings.TrimSuffix(outpath, outpathExtension(outpath)) + ".pmml", append([]byte(xml.Header), append(content, '\n')...))
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"proj3/data"
+	"proj3/gridsearch"
+	"proj3/regression"
+	"sync"
+)
+
+// The data of a task's search prepared for training: the search data with the options of the search on it, its
+// scaling, its scaled copy, the training options with the validation data scaled the same way, its view of the result
+// cache, and whether target transforms apply to it. Every goroutine of the search shares it, so it must not be modified
+type preparedTask struct {
+	taskData data.InputData
+	opts searchOptions
+	scaling regression.Scaling
+	normalized data.InputData
+	training gridsearch.TrainOptions
+	cache *taskCache
+	positiveY bool // every y of the search and refit data is > 0, as the log and boxcox target transforms need
+	hooks *gridsearch.Hooks // of the task searching on it, nil for none
+}
+
+// The prepared data of a run, by the task settings that change it, so the scaling statistics and the scaled copy of
+// the data are computed once per run for every way tasks prepare it rather than once per task. Guarded by a lock, as
+// reader goroutines prepare tasks concurrently
+type preparedData struct {
+	lock sync.Mutex
+	tasks map[string]*preparedTask
+}
+
+func newPreparedData() *preparedData {
+	return &preparedData{tasks: make(map[string]*preparedTask)}
+}
+
+// Returns the prepared data of a task searching on searchData, preparing it on first use
+func (p *preparedData) forTask(searchData data.InputData, opts searchOptions, task Hyperparameters) *preparedTask {
+	key := fmt.Sprintf("uncentered=%t", task.NoIntercept || task.NonNegative == "all")
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if prepared, ok := p.tasks[key]; ok {
+		return prepared
+	}
+	scaling := gridsearch.FitScaling(searchData, task.Grid, opts.scale)
+	prepared := &preparedTask{taskData: searchData, opts: opts, scaling: scaling, normalized: regression.Scale(searchData, scaling),
+		training: opts.trainingOptions(scaling), cache: opts.cache.forTask(searchData, opts),
+		positiveY: positive(searchData.Y) && (opts.refitData == nil || positive(opts.refitData.Y))}
+	p.tasks[key] = prepared
+	return prepared
+}
+
+// Reports whether every value is > 0
+func positive(values []float64) bool {
+	for _, value := range values {
+		if !(value > 0) {
+			return false
+		}
+	}
+	return true
+}
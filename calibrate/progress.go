@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"proj3/gridsearch"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Width of the progress bar in characters, and the least time between two redraws of it
+const (
+	progressBarWidth = 30
+	progressRedrawInterval = 200 * time.Millisecond
+)
+
+// Event sent to the progress aggregator: the start of a task with its number of permutations, or one more
+// permutation of a task evaluated, with its MSE and the epochs it trained for
+type progressUpdate struct {
+	outpath string
+	total int // permutations of a starting task, 0 for an evaluated permutation
+	mse float64
+	epochs int
+}
+
+// Progress of one task's search
+type taskProgress struct {
+	done int
+	total int
+	started time.Time
+	epochs int
+	bestMSE float64
+	beatDone int // permutations done at the last heartbeat
+	beatEpochs int // epochs trained at the last heartbeat
+}
+
+// Progress reporting of the permutations evaluated per task: a terminal progress bar, and heartbeat log lines every
+// heartbeat interval for runs whose output is captured by a scheduler rather than read on a terminal. Search
+// goroutines send their updates down a channel to a single aggregator goroutine, which owns the counts and writes
+// on stderr, so stdout stays clean
+type progress struct {
+	updates chan progressUpdate
+	finished chan bool
+	bar bool
+	heartbeat time.Duration // 0 for no heartbeat
+}
+
+// Creates the progress reporting and starts its aggregator goroutine
+func newProgress(bar bool, heartbeat time.Duration) *progress {
+	p := &progress{updates: make(chan progressUpdate, 1024), finished: make(chan bool), bar: bar, heartbeat: heartbeat}
+	go p.aggregate()
+	return p
+}
+
+// Records the start of a task's search over total permutations. A nil progress records nothing
+func (p *progress) start(outpath string, total int) {
+	if p == nil || total == 0 {
+		return
+	}
+	p.updates <- progressUpdate{outpath: outpath, total: total}
+}
+
+// Records one evaluated permutation of a task. A nil progress records nothing
+func (p *progress) evaluated(outpath string, mse float64, stats gridsearch.Stats) {
+	if p == nil {
+		return
+	}
+	p.updates <- progressUpdate{outpath: outpath, mse: mse, epochs: stats.Epochs}
+}
+
+// Aggregates updates until the channel is closed. The bar shows the task last updated, and a finished task's bar is
+// left on its own line. Heartbeats log every task still running
+func (p *progress) aggregate() {
+	tasks := make(map[string]*taskProgress)
+	lastDraw := time.Time{}
+	width := 0 //of the bar last drawn, which a shorter bar must be padded to cover
+	var beats <-chan time.Time
+	if p.heartbeat > 0 {
+		ticker := time.NewTicker(p.heartbeat)
+		defer ticker.Stop()
+		beats = ticker.C
+	}
+	for {
+		select {
+		case update, ok := <-p.updates:
+			if !ok {
+				p.finished <- true
+				return
+			}
+			if update.total > 0 {
+				tasks[update.outpath] = &taskProgress{total: update.total, started: time.Now(), bestMSE: -1}
+				continue
+			}
+			task, ok := tasks[update.outpath]
+			if !ok {
+				continue
+			}
+			task.done++
+			task.epochs += update.epochs
+			if task.bestMSE < 0 || update.mse < task.bestMSE {
+				task.bestMSE = update.mse
+			}
+			if task.done == task.total {
+				if p.bar {
+					fmt.Fprintf(os.Stderr, "\r%-*s\n", width, progressLine(update.outpath, task))
+				}
+				delete(tasks, update.outpath)
+				lastDraw, width = time.Time{}, 0
+			} else if p.bar && time.Since(lastDraw) >= progressRedrawInterval {
+				line := progressLine(update.outpath, task)
+				fmt.Fprintf(os.Stderr, "\r%-*s", width, line)
+				lastDraw, width = time.Now(), len(line)
+			}
+		case <-beats:
+			if width > 0 { //end the bar's line so the heartbeat starts on its own
+				fmt.Fprintln(os.Stderr)
+				width = 0
+			}
+			logHeartbeat(tasks, p.heartbeat)
+		}
+	}
+}
+
+// Formats the progress bar of a task with its permutations done out of its total and their throughput
+func progressLine(outpath string, task *taskProgress) string {
+	filled := progressBarWidth * task.done / task.total
+	throughput := float64(task.done) / time.Since(task.started).Seconds()
+	return fmt.Sprintf("[%s%s] %s %d/%d permutations, %.1f/s", strings.Repeat("#", filled),
+		strings.Repeat(".", progressBarWidth - filled), outpath, task.done, task.total, throughput)
+}
+
+// Logs one heartbeat line per running task, in order of outpath: its permutations and epochs per second since the
+// last heartbeat and the best MSE so far
+func logHeartbeat(tasks map[string]*taskProgress, interval time.Duration) {
+	outpaths := make([]string, 0, len(tasks))
+	for outpath := range tasks {
+		outpaths = append(outpaths, outpath)
+	}
+	sort.Strings(outpaths)
+	for _, outpath := range outpaths {
+		task := tasks[outpath]
+		bestWrite := "NA"
+		if task.bestMSE >= 0 {
+			bestWrite = fmt.Sprintf("%f", task.bestMSE)
+		}
+		log.Printf("heartbeat %s: %d/%d permutations, %.2f permutations/s, %.1f epochs/s, best MSE %s", outpath, task.done,
+			task.total, float64(task.done - task.beatDone) / interval.Seconds(), float64(task.epochs - task.beatEpochs) / interval.Seconds(),
+			bestWrite)
+		task.beatDone, task.beatEpochs = task.done, task.epochs
+	}
+}
+
+// Stops the aggregator once it has handled every update sent
+func (p *progress) Close() {
+	close(p.updates)
+	<- p.finished
+}
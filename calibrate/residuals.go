@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"proj3/data"
+	"proj3/gridsearch"
+	"proj3/regression"
+	"strings"
+)
+
+// Returns the residuals y - yhat of the winning model on the data it was fit on
+func winnerResiduals(parameters regression.Parameters, fitData data.InputData, hyperParams Hyperparameters) []float64 {
+	predicted := gridsearch.Forecast(parameters, fitData.X, hyperParams.Grid)
+	residuals := make([]float64, len(predicted))
+	for i := range predicted {
+		residuals[i] = fitData.Y[i] - predicted[i]
+	}
+	return residuals
+}
+
+// Returns the skewness, excess kurtosis and largest absolute value of residuals, formatted as results columns. Skewed
+// or heavy tailed residuals point at a misspecified model or outliers
+func residualSummary(residuals []float64) []string {
+	maxAbs := float64(0)
+	for _, residual := range residuals {
+		maxAbs = math.Max(maxAbs, math.Abs(residual))
+	}
+	return []string{fmt.Sprintf("%f", regression.Skewness(residuals)), fmt.Sprintf("%f", regression.Kurtosis(residuals)),
+		fmt.Sprintf("%f", maxAbs)}
+}
+
+// Writes x, y, the prediction, the residual and the standardized residual of every row the winner was fit on next to
+// the results file, eg results.csv to results_residuals.csv. Standardized residuals assume a linear model, so they are
+// left empty for generalized linear models and models without an intercept, and are those of the transformed y if the winner transforms its target
+func writeResiduals(outpath string, parameters regression.Parameters, fitData data.InputData, hyperParams Hyperparameters,
+	residuals []float64, policy writePolicy) {
+	extension := outpathExtension(outpath)
+	var standardized []float64
+	if hasLinearInference(hyperParams) {
+		standardized = regression.StandardizedResiduals(parameters, trainingTarget(fitData, hyperParams))
+	}
+	rows := [][]string{{"x", "y", "predicted", "residual", "standardizedResidual"}}
+	for i, residual := range residuals {
+		standardizedWrite := ""
+		if standardized != nil {
+			standardizedWrite = fmt.Sprintf("%f", standardized[i])
+		}
+		rows = append(rows, []string{fmt.Sprintf("%f", fitData.X[i]), fmt.Sprintf("%f", fitData.Y[i]),
+			fmt.Sprintf("%f", fitData.Y[i] - residual), fmt.Sprintf("%f", residual), standardizedWrite})
+	}
+	policy.writeCSV(strings.TrimSuffix(outpath, extension) + "_residuals" + extension, rows)
+}
+
+// Residuals whose lag-1 autocorrelation exceeds this in absolute value are flagged for -timeseries data
+const autocorrelationWarning = 0.2
+
+// Returns the Durbin-Watson statistic and lag-1 autocorrelation of residuals, formatted as results columns. Both need
+// the rows in file order, so they are NA when the winner was fit on a random sample. For -timeseries data a warning is
+// printed when the residuals are autocorrelated
+func autocorrelationSummary(residuals []float64, outpath string, opts searchOptions) []string {
+	if !opts.timeOrdered || len(residuals) < 2 {
+		return []string{"NA", "NA"}
+	}
+	durbinWatson := regression.DurbinWatson(residuals)
+	lag1 := regression.Autocorrelation(residuals, 1)
+	if opts.timeSeries && math.Abs(lag1) > autocorrelationWarning {
+		fmt.Fprintf(warningOutput(opts.machine), "Warning: residuals of %s are autocorrelated (Durbin-Watson %.3f, lag-1 %.3f); validate on contiguous time "+
+			"blocks, not random rows\n", outpath, durbinWatson, lag1)
+	}
+	return []string{fmt.Sprintf("%f", durbinWatson), fmt.Sprintf("%f", lag1)}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/metrics"
+	"strconv"
+	"strings"
+)
+
+// Runtime metrics of the CPU time the process has used, estimated by the Go runtime. The total counts every
+// GOMAXPROCS slot over the run's wall-clock time, idle ones included
+const (
+	cpuTotalMetric = "/cpu/classes/total:cpu-seconds"
+	cpuIdleMetric = "/cpu/classes/idle:cpu-seconds"
+	cpuUserMetric = "/cpu/classes/user:cpu-seconds"
+	cpuGCMetric = "/cpu/classes/gc/total:cpu-seconds"
+)
+
+// Prints the resources the run used: peak resident memory, total GC pause time, and CPU time in total, spent by Go
+// code and by the garbage collector, and per thread of -t (at least 1), so bigger grids can be planned from measurements
+func reportResources(numThreads int) {
+	samples := []metrics.Sample{{Name: cpuTotalMetric}, {Name: cpuIdleMetric}, {Name: cpuUserMetric}, {Name: cpuGCMetric}}
+	metrics.Read(samples)
+	cpu := make(map[string]float64)
+	for _, sample := range samples {
+		if sample.Value.Kind() == metrics.KindFloat64 {
+			cpu[sample.Name] = sample.Value.Float64()
+		}
+	}
+	var memStats runtime.MemStats
+	runtime.ReadMemStats(&memStats)
+	if numThreads < 1 {
+		numThreads = 1
+	}
+	busy := cpu[cpuTotalMetric] - cpu[cpuIdleMetric]
+
+	peakWrite := "NA"
+	if peak, ok := peakRSS(); ok {
+		peakWrite = fmt.Sprintf("%.1f MiB", float64(peak) / (1 << 20))
+	}
+	fmt.Println("Resource usage:")
+	fmt.Println("\tpeak RSS:", peakWrite)
+	fmt.Printf("\tGC pauses: %.3fs over %d collections\n", float64(memStats.PauseTotalNs) / 1e9, memStats.NumGC)
+	fmt.Printf("\tCPU time: %.3fs total, %.3fs in Go code, %.3fs in GC, %.3fs per thread over %d threads\n", busy,
+		cpu[cpuUserMetric], cpu[cpuGCMetric], busy / float64(numThreads), numThreads)
+}
+
+// Returns the peak resident set size of the process in bytes, read from the VmHWM line of /proc/self/status, and
+// false where there is no such file (outside Linux)
+func peakRSS() (int64, bool) {
+	file, err := os.Open("/proc/self/status")
+	if err != nil {
+		return 0, false
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 3 && fields[0] == "VmHWM:" && fields[2] == "kB" {
+			kilobytes, err := strconv.ParseInt(fields[1], 10, 64)
+			return kilobytes * 1024, err == nil
+		}
+	}
+	return 0, false
+}
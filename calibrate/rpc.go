@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+)
+
+// JSON-RPC 2.0 error codes of -rpc mode
+const (
+	rpcParseError = -32700
+	rpcMethodNotFound = -32601
+	rpcInvalidParams = -32602
+	rpcRefused = -32000 // a valid task the queue cannot take: its outpath is queued already, or the queue is closed
+	rpcFailed = -32001 // a queued task that cannot be searched on the data, eg a target transform of y <= 0
+)
+
+// A JSON-RPC 2.0 request, or a notification if it has no id
+type rpcRequest struct {
+	ID json.RawMessage `json:"id"`
+	Method string `json:"method"`
+	Params json.RawMessage `json:"params"`
+}
+
+// A JSON-RPC 2.0 response or notification
+type rpcMessage struct {
+	JSONRPC string `json:"jsonrpc"`
+	ID json.RawMessage `json:"id,omitempty"`
+	Method string `json:"method,omitempty"`
+	Params interface{} `json:"params,omitempty"`
+	Result interface{} `json:"result,omitempty"`
+	Error *rpcError `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code int `json:"code"`
+	Message string `json:"message"`
+}
+
+// The stdio protocol of -rpc mode, for wrappers driving calibrate as a subprocess: stdin holds JSON-RPC 2.0 requests,
+// one per line, and stdout only the responses and notifications. "submit" takes a JSON task as its params and queues
+// it into the search as -serve does; while it is searched every evaluated permutation is sent as a "progress"
+// notification, and once its results file is written the response to the submit request holds the task's summary, or
+// an error if the task cannot be searched on the data. "shutdown", as does the end of stdin, stops taking tasks and
+// exits once the queued ones are searched. A "ready" notification with the training data fingerprint is sent first,
+// once the data is loaded
+type rpcServer struct {
+	queue *jobQueue
+	out *resultStream
+	requests map[string]json.RawMessage // id of the submit request of every queued job, by job id, under the queue's lock
+}
+
+// Starts serving requests from in, writing to out. Returns the job queue the requests submit tasks to and the tasks,
+// which end once stdin ends or shutdown is requested
+func serveRPC(in io.Reader, out *resultStream, fingerprint string, audit *auditLog) (*jobQueue, io.Reader) {
+	queue, tasks := newJobQueue(audit)
+	s := &rpcServer{queue: queue, out: out, requests: make(map[string]json.RawMessage)}
+	queue.done = s.finished
+	out.write(rpcMessage{JSONRPC: "2.0", Method: "ready", Params: map[string]string{"dataFingerprint": fingerprint}})
+	go s.serve(in)
+	return queue, tasks
+}
+
+func (s *rpcServer) serve(in io.Reader) {
+	dec := json.NewDecoder(in)
+	for {
+		var request rpcRequest
+		if err := dec.Decode(&request); err == io.EOF {
+			break
+		} else if err != nil {
+			s.out.write(rpcMessage{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &rpcError{rpcParseError, err.Error()}})
+			break //the decoder cannot resynchronize
+		}
+		s.handle(request)
+	}
+	s.queue.close()
+}
+
+func (s *rpcServer) handle(request rpcRequest) {
+	switch request.Method {
+	case "submit":
+		var j jsonInput
+		if err := json.Unmarshal(request.Params, &j); err != nil {
+			s.reply(request, nil, &rpcError{rpcInvalidParams, "cannot decode task: " + err.Error()})
+			return
+		}
+		_, status, err := s.queue.enqueue(j, func(queued *job) {
+			if request.ID != nil {
+				s.requests[queued.ID] = request.ID
+			}
+		})
+		if err != nil {
+			code := rpcRefused
+			if status == http.StatusBadRequest {
+				code = rpcInvalidParams
+			}
+			s.reply(request, nil, &rpcError{code, err.Error()})
+			return
+		}
+	case "shutdown":
+		s.queue.close()
+		s.reply(request, "stopping", nil)
+	default:
+		s.reply(request, nil, &rpcError{rpcMethodNotFound, "unknown method " + request.Method})
+	}
+}
+
+// Answers a request, unless it is a notification
+func (s *rpcServer) reply(request rpcRequest, result interface{}, err *rpcError) {
+	if request.ID == nil {
+		return
+	}
+	s.out.write(rpcMessage{JSONRPC: "2.0", ID: request.ID, Result: result, Error: err})
+}
+
+// Answers the submit request of a finished job, or with an error one that failed. Called under the queue's lock
+func (s *rpcServer) finished(finished *job) {
+	id, ok := s.requests[finished.ID]
+	if !ok {
+		return
+	}
+	delete(s.requests, finished.ID)
+	if finished.Status == "failed" {
+		s.out.write(rpcMessage{JSONRPC: "2.0", ID: id, Error: &rpcError{rpcFailed, finished.Reason}})
+		return
+	}
+	s.out.write(rpcMessage{JSONRPC: "2.0", ID: id, Result: finished.Result})
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"log"
+	"time"
+)
+
+// A debug trace of how the parallel search schedules its work: which reader picked up which tasks, which slices of a
+// task's permutations went to which goroutines and how long tasks and readers waited, to find out why a run does not
+// scale with -t. Every line is stamped with the seconds since the search started. A nil trace logs nothing
+type scheduleTrace struct {
+	start time.Time
+}
+
+// Creates a trace whose clock starts now
+func newScheduleTrace() *scheduleTrace {
+	return &scheduleTrace{start: time.Now()}
+}
+
+// Logs one line of the trace
+func (t *scheduleTrace) logf(format string, args ...interface{}) {
+	if t == nil {
+		return
+	}
+	log.Printf("schedule %9.3fs: " + format, append([]interface{}{time.Since(t.start).Seconds()}, args...)...)
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// A task submitted to the job queue, as GET /tasks/{id}/result answers it
+type job struct {
+	ID string `json:"id"`
+	Status string `json:"status"` // "queued" until its results file is written, then "finished", or "failed" if it cannot be searched
+	Outpath string `json:"outpath"`
+	Result *taskSummary `json:"result,omitempty"`
+	Reason string `json:"reason,omitempty"` // why it failed
+}
+
+// The HTTP job queue of -serve mode, which keeps calibrate running to search tasks as they are submitted, and of
+// -rpc mode, whose requests submit to it. POST /tasks
+// validates a JSON task and queues it, answering with its job; GET /tasks/{id}/result answers with the job, holding
+// the summary of its result once its results file is written. Queued tasks are fed in order into a pipe the search
+// reads as it would read stdin, so the reader and worker goroutines pick them up as they arrive; the backlog keeps
+// submissions from waiting on busy readers. Two queued jobs cannot share an outpath, as the later would overwrite the
+// earlier's results. A nil queue records nothing
+type jobQueue struct {
+	lock sync.Mutex
+	jobs map[string]*job // by id
+	queued map[string]*job // jobs not finished yet, by cleaned outpath
+	backlog []jsonInput // tasks submitted but not yet fed to the search
+	fed *sync.Cond // signalled when the backlog grows or the queue closes
+	closed bool
+	tasks *io.PipeWriter
+	audit *auditLog
+	received int // tasks received so far, numbering them in the audit log
+	done func(finished *job) // called with every finished job, under the lock, if set
+}
+
+// Starts serving the job queue on addr, eg ":8080", in the background. Returns the queue and the tasks submitted to
+// it, which end once the process is interrupted or terminated so the search can finish the queued ones and return
+func serveJobs(addr string, audit *auditLog) (*jobQueue, io.Reader) {
+	q, reader := newJobQueue(audit)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/tasks", q.submit)
+	mux.HandleFunc("/tasks/", q.result)
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		log.Fatal("Error: cannot listen for tasks on ", addr, ": ", err)
+	}
+	go http.Serve(listener, mux)
+	return q, reader
+}
+
+// Creates an empty job queue, closed once the process is interrupted or terminated. Returns the queue and the tasks
+// submitted to it
+func newJobQueue(audit *auditLog) (*jobQueue, io.Reader) {
+	reader, writer := io.Pipe()
+	q := &jobQueue{jobs: make(map[string]*job), queued: make(map[string]*job), tasks: writer, audit: audit}
+	q.fed = sync.NewCond(&q.lock)
+	go q.feed()
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-stop
+		q.close()
+	}()
+	return q, reader
+}
+
+// Stops taking tasks, ending the tasks read by the search once the queued ones are fed
+func (q *jobQueue) close() {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if !q.closed {
+		log.Println("Stopping: no new tasks are taken, the queued ones are searched")
+	}
+	q.closed = true
+	q.fed.Broadcast()
+}
+
+// Feeds the backlog into the search's pipe in order, closing the pipe once the queue is closed and the backlog fed
+func (q *jobQueue) feed() {
+	enc := json.NewEncoder(q.tasks)
+	for {
+		q.lock.Lock()
+		for len(q.backlog) == 0 && !q.closed {
+			q.fed.Wait()
+		}
+		if len(q.backlog) == 0 {
+			q.lock.Unlock()
+			q.tasks.Close()
+			return
+		}
+		next := q.backlog[0]
+		q.backlog = q.backlog[1:]
+		q.lock.Unlock()
+		enc.Encode(next)
+	}
+}
+
+// Handles POST /tasks
+func (q *jobQueue) submit(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST a JSON task", http.StatusMethodNotAllowed)
+		return
+	}
+	var j jsonInput
+	if err := json.NewDecoder(r.Body).Decode(&j); err != nil {
+		http.Error(w, "cannot decode task: " + err.Error(), http.StatusBadRequest)
+		return
+	}
+	submitted, status, err := q.enqueue(j, nil)
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(submitted)
+}
+
+// Validates a task and queues it, calling queued, unless it is nil, with its job before the search can take it.
+// Returns the job, or an error and the HTTP status telling why the task was refused: it is invalid, another queued
+// job writes its outpath, or the queue is closed
+func (q *jobQueue) enqueue(j jsonInput, queued func(*job)) (*job, int, error) {
+	hyperParams, err := parseTask(j)
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	q.received++
+	q.audit.received(q.received, j)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	outpath := filepath.Clean(j.Outpath)
+	if earlier, ok := q.queued[outpath]; ok {
+		return nil, http.StatusConflict, errors.New("job " + earlier.ID + " already writes " + j.Outpath)
+	}
+	if q.closed {
+		return nil, http.StatusServiceUnavailable, errors.New("the queue is closed")
+	}
+	q.audit.validated(q.received, j.Outpath, len(createArrayParamPermutations(hyperParams)))
+	submitted := &job{ID: randomHex(8), Status: "queued", Outpath: j.Outpath}
+	q.jobs[submitted.ID], q.queued[outpath] = submitted, submitted
+	if queued != nil {
+		queued(submitted)
+	}
+	q.backlog = append(q.backlog, j)
+	q.fed.Signal()
+	return submitted, 0, nil
+}
+
+// Handles GET /tasks/{id}/result
+func (q *jobQueue) result(w http.ResponseWriter, r *http.Request) {
+	id, rest, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/tasks/"), "/")
+	if rest != "result" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "GET the result", http.StatusMethodNotAllowed)
+		return
+	}
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	found, ok := q.jobs[id]
+	if !ok {
+		http.Error(w, "no job " + id, http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(found)
+}
+
+// Records why a task could not be searched into its job
+func (q *jobQueue) failed(outpath string, reason string) {
+	if q == nil {
+		return
+	}
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	outpath = filepath.Clean(outpath)
+	if failed, ok := q.queued[outpath]; ok {
+		failed.Status, failed.Reason = "failed", reason
+		delete(q.queued, outpath)
+		if q.done != nil {
+			q.done(failed)
+		}
+	}
+}
+
+// Records the result of a finished task into its job
+func (q *jobQueue) finished(summary taskSummary) {
+	if q == nil {
+		return
+	}
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	outpath := filepath.Clean(summary.Task)
+	if finished, ok := q.queued[outpath]; ok {
+		finished.Status, finished.Result = "finished", &summary
+		delete(q.queued, outpath)
+		if q.done != nil {
+			q.done(finished)
+		}
+	}
+}
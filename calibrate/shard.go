@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// One of count shards of every task's permutations, so count independent instances, eg the pods of a cluster, can
+// each search part of the same task file. Permutation i of a task belongs to shard i mod count: shards are disjoint,
+// cover the grid, get permutations from every part of it, and are the same on every instance. Each shard writes its
+// winner into its own results file, which calibrate merge combines afterwards. A nil shard searches the whole grid
+type shard struct {
+	index int
+	count int
+}
+
+// Creates shard index of count, or returns nil for a single shard
+func newShard(index int, count int) *shard {
+	if count < 1 || index < 0 || index >= count {
+		log.Fatal("Error: -shard-index must be in [0, -shard-count), got ", index, " of ", count)
+	}
+	if count == 1 {
+		return nil
+	}
+	return &shard{index: index, count: count}
+}
+
+// Returns the permutations of the shard
+func (s *shard) take(permutations []Hyperparameters) []Hyperparameters {
+	if s == nil {
+		return permutations
+	}
+	taken := make([]Hyperparameters, 0, len(permutations) / s.count + 1)
+	for i := s.index; i < len(permutations); i += s.count {
+		taken = append(taken, permutations[i])
+	}
+	return taken
+}
+
+// Returns the results file of the shard for a task's outpath
+func (s *shard) outpath(outpath string) string {
+	if s == nil {
+		return outpath
+	}
+	return shardOutpath(outpath, s.index, s.count)
+}
+
+// Names the results file of shard index of count, eg results_shard2of4.csv for results.csv
+func shardOutpath(outpath string, index int, count int) string {
+	extension := outpathExtension(outpath)
+	return fmt.Sprintf("%s_shard%dof%d%s", strings.TrimSuffix(outpath, extension), index, count, extension)
+}
+
+// Entry point of the merge subcommand: calibrate merge -o="results.csv" -shard-count=4
+// Combines the results files of the shards of a task into its results file, keeping the row of the shard whose
+// winner has the lowest MSE, so the result is the winner of the whole grid. Its trainSeconds and taskSeconds are
+// those of its shard
+func merge(args []string) {
+	flags := flag.NewFlagSet("merge", flag.ExitOnError)
+	outpath := flags.String("o", "", "results file of the task, whose shard files are merged into it")
+	count := flags.Int("shard-count", 0, "number of shards the task was searched in")
+	flags.Parse(args)
+	if *outpath == "" || *count < 1 {
+		fmt.Println("Usage: calibrate merge -o=\"results.csv\" -shard-count=4")
+		os.Exit(0)
+	}
+
+	var header, best []string
+	bestMSE := 0.0
+	for i := 0; i < *count; i++ {
+		path := shardOutpath(*outpath, i, *count)
+		file, closeFile, err := openResults(path)
+		if err != nil {
+			log.Fatal("Error: cannot read the results of shard ", i, ": ", err)
+		}
+		rows, err := csv.NewReader(file).ReadAll()
+		closeFile()
+		if err != nil || len(rows) < 2 {
+			log.Fatal("Error: ", path, " is not a results file")
+		}
+		if header == nil {
+			header = rows[0]
+		}
+		mseColumn := -1
+		for column, name := range rows[0] {
+			if name == "mse" {
+				mseColumn = column
+			}
+		}
+		if mseColumn < 0 || len(rows[0]) != len(header) {
+			log.Fatal("Error: ", path, " does not have the columns of the other shards' results")
+		}
+		mse, err := strconv.ParseFloat(rows[1][mseColumn], 64)
+		if err != nil { //a shard without a finite winner
+			continue
+		}
+		if best == nil || mse < bestMSE {
+			best, bestMSE = rows[1], mse
+		}
+	}
+	if best == nil {
+		log.Fatal("Error: no shard of ", *outpath, " has a winner")
+	}
+	writePolicy{}.writeCSV(*outpath, [][]string{header, best})
+	fmt.Println("Merged", *count, "shards into filepath:", *outpath, "- mse", bestMSE)
+}
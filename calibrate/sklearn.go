@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"proj3/gridsearch"
+	"proj3/regression"
+	"strings"
+)
+
+// A winning model in the terms of scikit-learn: the estimator it corresponds to, the constructor parameters of
+// get_params() and the fitted attributes, so setting them onto a fresh estimator reproduces its predictions.
+// Target is the transformation y was fit on, to wrap the estimator in a TransformedTargetRegressor, and Calibrate
+// holds the winning hyperparameters by their results column name, including those sklearn has no parameter for
+type sklearnExport struct {
+	Estimator string `json:"estimator"`
+	Params map[string]interface{} `json:"params"`
+	Attributes map[string]interface{} `json:"attributes"`
+	Target *sklearnTarget `json:"target,omitempty"`
+	Calibrate map[string]string `json:"calibrate"`
+}
+
+type sklearnTarget struct {
+	Transform string `json:"transform"` // "log" or "boxcox"
+	Lambda float64 `json:"lambda"`
+}
+
+// Writes the winning model as sklearn parameters and attributes next to the results file, eg results_sklearn.json for
+// results.csv. Gradient descent and its momentum variant map to SGDRegressor, the other optimizers, which solve the
+// least squares problem, to LinearRegression, and generalized linear models to PoissonRegressor and GammaRegressor,
+// or TweedieRegressor for links other than log. Coefficients are on the original scale of x, as sklearn fits raw x
+func writeSklearn(outpath string, parameters regression.Parameters, stats gridsearch.Stats, hyperParams Hyperparameters,
+	policy writePolicy) {
+	lambda := 0.0
+	if hyperParams.Lambda != nil {
+		lambda = hyperParams.Lambda[0]
+	}
+	var tolerance interface{}
+	if hyperParams.Tolerance != nil {
+		tolerance = hyperParams.Tolerance[0]
+	}
+	loss := "squared"
+	if hyperParams.Loss != nil {
+		loss = hyperParams.Loss[0]
+	}
+	export := sklearnExport{Params: map[string]interface{}{"fit_intercept": !hyperParams.NoIntercept},
+		Attributes: map[string]interface{}{"coef_": []float64{parameters.Beta}, "intercept_": parameters.Mu,
+			"n_features_in_": 1, "feature_names_in_": []string{"x"}}, Calibrate: hyperparamMap(hyperParams)}
+	switch {
+	case gridsearch.IsGLM(loss):
+		export.Estimator = map[string]string{"poisson": "PoissonRegressor", "gamma": "GammaRegressor"}[loss]
+		if hyperParams.Link[0] != "log" {
+			export.Estimator = "TweedieRegressor"
+			export.Params["power"] = map[string]int{"poisson": 1, "gamma": 2}[loss]
+			export.Params["link"] = hyperParams.Link[0]
+		}
+		export.Params["alpha"], export.Params["max_iter"], export.Params["tol"] = lambda, int(hyperParams.NumEpochs[0]), tolerance
+		export.Attributes["n_iter_"] = stats.Epochs
+	case hyperParams.Optimizer[0] == "gd" || hyperParams.Optimizer[0] == "nag" || loss != "squared":
+		export.Estimator = "SGDRegressor"
+		export.Params["loss"], export.Params["penalty"], export.Params["alpha"] = "squared_error", nil, lambda
+		if lambda > 0 {
+			export.Params["penalty"] = "l2"
+		}
+		if loss == "epsilon" {
+			export.Params["loss"], export.Params["epsilon"] = "epsilon_insensitive", hyperParams.Epsilon[0]
+		} else if loss != "squared" { //a registered loss sklearn does not know, kept by name
+			export.Params["loss"] = loss
+		}
+		export.Params["learning_rate"], export.Params["eta0"] = "constant", hyperParams.Alpha[0]
+		if hyperParams.Schedule != nil && hyperParams.Schedule[0] == "plateau" {
+			export.Params["learning_rate"] = "adaptive"
+		}
+		export.Params["max_iter"], export.Params["tol"], export.Params["random_state"] = int(hyperParams.NumEpochs[0]), tolerance, nil
+		if hyperParams.Seed != 0 {
+			export.Params["random_state"] = hyperParams.Seed
+		}
+		export.Attributes["intercept_"] = []float64{parameters.Mu}
+		export.Attributes["n_iter_"] = stats.Epochs
+	default:
+		export.Estimator = "LinearRegression"
+		export.Params["positive"] = hyperParams.NonNegative != ""
+	}
+	if _, ok := gridsearch.TargetTransform(hyperParams.Grid); ok {
+		export.Target = &sklearnTarget{Transform: hyperParams.Target[0]}
+		if hyperParams.Target[0] == "boxcox" {
+			export.Target.Lambda = hyperParams.BoxCox[0]
+		}
+	}
+
+	content, err := json.MarshalIndent(export, "", "\t")
+	if err != nil {
+		log.Fatal("Error: cannot encode the sklearn parameters of ", outpath, ": ", err)
+	}
+	extension := outpathExtension(outpath)
+	policy.write(strings.TrimSuffix(outpath, extension) + "_sklearn.json", append(content, '\n'))
+}
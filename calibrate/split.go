@@ -0,0 +1,60 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"proj3/data"
+	"strconv"
+	"strings"
+)
+
+// Entry point of the split subcommand: calibrate split -i="filename.csv" -fracs=0.7,0.15,0.15 -seed=1
+// Shuffles the input data and writes one csv per fraction next to the input file
+func split(args []string) {
+	flags := flag.NewFlagSet("split", flag.ExitOnError)
+	inpath := flags.String("i", "", "filepath of the input data csv file")
+	fracsFlag := flags.String("fracs", "0.7,0.15,0.15", "comma separated fractions of rows in each output file")
+	seed := flags.Int64("seed", 1, "seed of the shuffle, so a split can be reproduced")
+	stratify := flags.Int("stratify", 0, "split within this many quantile bins of y, so every file covers the full range of y")
+	categorical := flags.String("categorical", "", "comma separated indexes of categorical csv columns, written one-hot encoded")
+	flags.Parse(args)
+	if *inpath == "" {
+		fmt.Println("Usage: calibrate split -i=\"filename.csv\" -fracs=0.7,0.15,0.15 -seed=1")
+		os.Exit(0)
+	}
+
+	fracs := stringToFloat64(strings.Split(*fracsFlag, ","))
+	rawData := data.LoadTrainingData(*inpath, data.LoadOptions{Missing: "keep", Categorical: parseColumnIndexes(*categorical)})
+	var parts []data.InputData
+	var err error
+	if *stratify > 0 {
+		parts, err = data.SplitStratified(rawData, fracs, *stratify, *seed)
+	} else {
+		parts, err = data.Split(rawData, fracs, *seed)
+	}
+	if err != nil {
+		log.Fatal("Error: ", err)
+	}
+	for i, part := range parts {
+		outpath := splitOutputPath(*inpath, i, len(parts))
+		if err := data.WriteTrainingData(part, outpath); err != nil {
+			log.Fatal("Error: ", err)
+		}
+		fmt.Println("Wrote", len(part.X), "rows into filepath:", outpath)
+	}
+}
+
+// Names the output file of split part i: train/test for two parts, train/val/test for three, numbered parts otherwise
+func splitOutputPath(inpath string, i int, numParts int) string {
+	base := strings.TrimSuffix(inpath, ".csv")
+	switch {
+	case numParts == 2:
+		return base + "_" + []string{"train", "test"}[i] + ".csv"
+	case numParts == 3:
+		return base + "_" + []string{"train", "val", "test"}[i] + ".csv"
+	default:
+		return base + "_part" + strconv.Itoa(i + 1) + ".csv"
+	}
+}
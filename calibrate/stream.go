@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"proj3/gridsearch"
+	"proj3/regression"
+	"sync"
+)
+
+// One evaluated permutation as streamed by -stream-results. Hyperparameters holds its searched dimensions by their
+// results column name; metrics that are not finite are null
+type configResult struct {
+	Kind string `json:"kind"` // always "permutation"
+	Task string `json:"task"`
+	Hyperparameters map[string]string `json:"hyperparameters"`
+	Beta *float64 `json:"beta"`
+	Mu *float64 `json:"mu"`
+	MSE *float64 `json:"mse"`
+	ConvergedEpoch *int `json:"convergedEpoch"`
+	Epochs int `json:"epochs"`
+	TrainSeconds float64 `json:"trainSeconds"`
+	Error string `json:"error,omitempty"` // why its metrics are null, eg regression.ErrDiverged
+}
+
+// A stream of JSON objects, one per line: every evaluated permutation as it finishes when permutations is set, so a
+// downstream consumer can react in real time instead of waiting for the results files, and in -machine mode the
+// summary of every finished task, with "kind" telling them apart. Search goroutines write concurrently, so writes are
+// serialized by a lock. In -rpc mode permutations are JSON-RPC "progress" notifications and task summaries are left to
+// the responses. Every permutation is also handed to the sinks, whether or not it is streamed to stdout. A nil stream
+// writes nothing
+type resultStream struct {
+	lock sync.Mutex
+	enc *json.Encoder // nil for a stream that only feeds its sinks
+	permutations bool
+	rpc bool
+	sinks []permutationSink
+}
+
+// A destination of every evaluated permutation besides stdout, eg the -publish bus or the -results-dsn database.
+// Search goroutines call it concurrently
+type permutationSink interface {
+	permutation(result configResult)
+}
+
+// Creates a stream writing to w
+func newResultStream(w io.Writer, permutations bool) *resultStream {
+	return &resultStream{enc: json.NewEncoder(w), permutations: permutations}
+}
+
+// Streams one evaluated permutation
+func (s *resultStream) record(hyperParams Hyperparameters, parameters regression.Parameters, mse float64, stats gridsearch.Stats) {
+	if s == nil || !s.permutations && len(s.sinks) == 0 {
+		return
+	}
+	result := configResult{Kind: "permutation", Task: hyperParams.Outpath, Hyperparameters: hyperparamMap(hyperParams),
+		Beta: finite(parameters.Beta), Mu: finite(parameters.Mu), MSE: finite(mse), Epochs: stats.Epochs, TrainSeconds: stats.Seconds}
+	if stats.ConvergedEpoch >= 0 {
+		result.ConvergedEpoch = &stats.ConvergedEpoch
+	}
+	if err := regression.CheckDiverged(parameters, mse); err != nil {
+		result.Error = err.Error()
+	}
+	for _, sink := range s.sinks {
+		sink.permutation(result)
+	}
+	if !s.permutations {
+		return
+	}
+	if s.rpc {
+		s.write(rpcMessage{JSONRPC: "2.0", Method: "progress", Params: result})
+		return
+	}
+	s.write(result)
+}
+
+// Streams the summary of a finished task
+func (s *resultStream) finished(summary taskSummary) {
+	if s == nil || s.rpc || s.enc == nil {
+		return
+	}
+	s.write(struct {
+		Kind string `json:"kind"`
+		taskSummary
+	}{"task", summary})
+}
+
+func (s *resultStream) write(value interface{}) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if err := s.enc.Encode(value); err != nil {
+		log.Fatal("Error: cannot stream results: ", err)
+	}
+}
+
+// Where human readable output goes: stdout, or nowhere in -machine mode, whose stdout holds JSON lines only
+func humanOutput(machine bool) io.Writer {
+	if machine {
+		return io.Discard
+	}
+	return os.Stdout
+}
+
+// Where warnings go: stdout with the rest of the human readable output, or stderr in -machine mode
+func warningOutput(machine bool) io.Writer {
+	if machine {
+		return os.Stderr
+	}
+	return os.Stdout
+}
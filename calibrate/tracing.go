@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Spans the exporter batches into one OTLP request, and how long it waits for an answer
+const (
+	otelBatchSize = 512
+	otelTimeout = 10 * time.Second
+)
+
+// One finished span in the OTLP/HTTP JSON encoding, whose trace and span ids are hex strings and whose times are
+// decimal strings of Unix nanoseconds
+type otlpSpan struct {
+	TraceID string `json:"traceId"`
+	SpanID string `json:"spanId"`
+	ParentSpanID string `json:"parentSpanId,omitempty"`
+	Name string `json:"name"`
+	Kind int `json:"kind"`
+	StartTimeUnixNano string `json:"startTimeUnixNano"`
+	EndTimeUnixNano string `json:"endTimeUnixNano"`
+	Attributes []otlpAttribute `json:"attributes,omitempty"`
+}
+
+// A string attribute of a span or resource in the OTLP/HTTP JSON encoding
+type otlpAttribute struct {
+	Key string `json:"key"`
+	Value struct {
+		StringValue string `json:"stringValue"`
+	} `json:"value"`
+}
+
+// Exports OpenTelemetry spans of a search to an OTLP/HTTP collector, eg http://localhost:4318, with nothing but the
+// standard library. Every span of a run shares one trace, which continues the W3C TRACEPARENT environment variable
+// when a caller sets it, so a search started by another traced service shows up inside that service's trace.
+// Finished spans are sent down a channel to a single exporter goroutine, which posts them in batches
+type tracer struct {
+	url string
+	traceID string
+	parentID string // span id of the caller's span from TRACEPARENT, empty if there is none
+	spans chan otlpSpan
+	finished chan bool
+}
+
+// A span being timed. A nil span records nothing, so untraced runs can call its methods freely
+type span struct {
+	tracer *tracer
+	id string
+	parentID string
+	name string
+	start time.Time
+	attributes []otlpAttribute
+}
+
+// Creates a tracer exporting to the collector at endpoint and starts its exporter goroutine
+func newTracer(endpoint string) *tracer {
+	t := &tracer{url: strings.TrimSuffix(endpoint, "/") + "/v1/traces", traceID: randomHex(16), spans: make(chan otlpSpan, otelBatchSize),
+		finished: make(chan bool)}
+	if traceID, parentID, ok := parseTraceparent(os.Getenv("TRACEPARENT")); ok {
+		t.traceID, t.parentID = traceID, parentID
+	}
+	go t.export()
+	return t
+}
+
+// Parses a W3C traceparent header, version-traceid-parentid-flags, into its trace id and parent span id
+func parseTraceparent(traceparent string) (string, string, bool) {
+	fields := strings.Split(strings.TrimSpace(traceparent), "-")
+	if len(fields) != 4 || len(fields[1]) != 32 || len(fields[2]) != 16 {
+		return "", "", false
+	}
+	if _, err := hex.DecodeString(fields[1] + fields[2]); err != nil {
+		return "", "", false
+	}
+	return fields[1], fields[2], true
+}
+
+// Returns n random bytes as a hex string, for trace and span ids
+func randomHex(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		log.Fatal("Error: cannot generate a trace id", err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// Starts a top level span of the run, a child of the caller's span if TRACEPARENT gave one. A nil tracer starts nil
+func (t *tracer) start(name string) *span {
+	if t == nil {
+		return nil
+	}
+	return &span{tracer: t, id: randomHex(8), parentID: t.parentID, name: name, start: time.Now()}
+}
+
+// Starts a child span
+func (s *span) child(name string) *span {
+	if s == nil {
+		return nil
+	}
+	return &span{tracer: s.tracer, id: randomHex(8), parentID: s.id, name: name, start: time.Now()}
+}
+
+// Sets a string attribute of the span
+func (s *span) set(key string, value string) {
+	if s == nil {
+		return
+	}
+	attribute := otlpAttribute{Key: key}
+	attribute.Value.StringValue = value
+	s.attributes = append(s.attributes, attribute)
+}
+
+// Ends the span and hands it to the exporter
+func (s *span) end() {
+	if s == nil {
+		return
+	}
+	s.tracer.spans <- otlpSpan{TraceID: s.tracer.traceID, SpanID: s.id, ParentSpanID: s.parentID, Name: s.name, Kind: 1,
+		StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10), EndTimeUnixNano: strconv.FormatInt(time.Now().UnixNano(), 10),
+		Attributes: s.attributes}
+}
+
+// Posts finished spans in batches until the channel is closed, then posts the rest. A collector that cannot be
+// reached is logged once and otherwise ignored, as tracing must not fail a search
+func (t *tracer) export() {
+	client := &http.Client{Timeout: otelTimeout}
+	batch := make([]otlpSpan, 0, otelBatchSize)
+	failed := false
+	post := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := t.post(client, batch); err != nil && !failed {
+			log.Println("Warning: cannot export trace spans to", t.url, "-", err)
+			failed = true
+		}
+		batch = batch[:0]
+	}
+	for s := range t.spans {
+		batch = append(batch, s)
+		if len(batch) == otelBatchSize {
+			post()
+		}
+	}
+	post()
+	t.finished <- true
+}
+
+// Posts one batch of spans as an OTLP/HTTP JSON export request
+func (t *tracer) post(client *http.Client, batch []otlpSpan) error {
+	service := otlpAttribute{Key: "service.name"}
+	service.Value.StringValue = "calibrate"
+	request := map[string]interface{}{"resourceSpans": []interface{}{map[string]interface{}{
+		"resource": map[string]interface{}{"attributes": []otlpAttribute{service}},
+		"scopeSpans": []interface{}{map[string]interface{}{"scope": map[string]string{"name": "calibrate"}, "spans": batch}},
+	}}}
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	response, err := client.Post(t.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	response.Body.Close()
+	if response.StatusCode / 100 != 2 {
+		return fmt.Errorf("collector answered %s", response.Status)
+	}
+	return nil
+}
+
+// Exports the spans not yet posted and stops the exporter
+func (t *tracer) Close() {
+	close(t.spans)
+	<- t.finished
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"proj3/data"
+)
+
+// Entry point of the validate subcommand: calibrate validate -i="filename.csv"
+// Prints a pass/fail report of a training data file and exits with status 1 if it fails, so it can gate a long search
+func validate(args []string) {
+	flags := flag.NewFlagSet("validate", flag.ExitOnError)
+	inpath := flags.String("i", "", "filepath of the input data csv file")
+	categorical := flags.String("categorical", "", "comma separated indexes of categorical csv columns")
+	flags.Parse(args)
+	if *inpath == "" {
+		fmt.Println("Usage: calibrate validate -i=\"filename.csv\"")
+		os.Exit(0)
+	}
+
+	report := data.ValidateTrainingData(*inpath, parseColumnIndexes(*categorical))
+	fmt.Println("File:", *inpath)
+	fmt.Println("Rows:", report.Rows)
+	printCheck("rows with a different number of columns", report.MismatchedRows)
+	printCheck("cells that are not numbers", report.UnparseableCells)
+	printCheck("NaN cells", report.NaNCells)
+	printCheck("Inf cells", report.InfCells)
+	printCheck("duplicate rows", report.DuplicateRows)
+	if report.ConstantX {
+		fmt.Println("FAIL  x is constant, so beta cannot be calibrated")
+	} else {
+		fmt.Println("ok    x is not constant")
+	}
+	if report.MissingCells > 0 {
+		fmt.Println("warn ", report.MissingCells, "missing cells, handled by the -missing strategy when loading")
+	}
+
+	if !report.Passed() {
+		fmt.Println("Validation FAILED")
+		os.Exit(1)
+	}
+	fmt.Println("Validation passed")
+}
+
+// Prints one line of the validation report
+func printCheck(description string, issue data.ValidationIssue) {
+	if issue.Count == 0 {
+		fmt.Println("ok    no", description)
+		return
+	}
+	fmt.Println("FAIL ", issue.Count, description, "eg at lines", issue.Lines)
+}
@@ -0,0 +1,72 @@
+// The search engine as a C shared library, for C and Python processes that already hold the data in memory. Build it
+// with
+//
+//	go build -buildmode=c-shared -o libcalibrate.so ./cshared
+//
+// which also writes libcalibrate.h declaring
+//
+//	char* Calibrate(double* x, double* y, int n, char* grid, int numThreads);
+//	void CalibrateFree(char* result);
+//
+// From Python:
+//
+//	lib = ctypes.CDLL("./libcalibrate.so")
+//	lib.Calibrate.restype = ctypes.c_void_p
+//	x, y = numpy.ascontiguousarray(x, dtype=numpy.float64), numpy.ascontiguousarray(y, dtype=numpy.float64)
+//	p = lib.Calibrate(x.ctypes.data_as(ctypes.POINTER(ctypes.c_double)), y.ctypes.data_as(ctypes.POINTER(ctypes.c_double)),
+//		len(x), b'{"alpha": [0.1, 0.5], "numEpochs": [100], "optimizer": ["gd", "nag"]}', 8)
+//	result = json.loads(ctypes.string_at(p)); lib.CalibrateFree(ctypes.c_void_p(p))
+package main
+
+/*
+#include <stdlib.h>
+*/
+import "C"
+
+import (
+	"encoding/json"
+	"proj3/data"
+	"proj3/gridsearch"
+	"unsafe"
+)
+
+// Searches the grid, a JSON gridsearch.Grid, on the n rows of x and y with numThreads goroutines. x and y are read in
+// place, not copied, and must not change during the call. Returns the JSON gridsearch.Result of the winner, or
+// {"error": "..."} if the search cannot run; either is allocated in C and released with CalibrateFree
+//
+//export Calibrate
+func Calibrate(x *C.double, y *C.double, n C.int, grid *C.char, numThreads C.int) *C.char {
+	var g gridsearch.Grid
+	if err := json.Unmarshal([]byte(C.GoString(grid)), &g); err != nil {
+		return encodeError(err)
+	}
+	var d data.InputData
+	if n > 0 {
+		d.X = unsafe.Slice((*float64)(unsafe.Pointer(x)), int(n))
+		d.Y = unsafe.Slice((*float64)(unsafe.Pointer(y)), int(n))
+	}
+	result, err := gridsearch.Search(d, g, int(numThreads))
+	if err != nil {
+		return encodeError(err)
+	}
+	encoded, err := json.Marshal(result)
+	if err != nil { //a winner whose MSE overflowed
+		return encodeError(err)
+	}
+	return C.CString(string(encoded))
+}
+
+// Releases a result returned by Calibrate
+//
+//export CalibrateFree
+func CalibrateFree(result *C.char) {
+	C.free(unsafe.Pointer(result))
+}
+
+func encodeError(err error) *C.char {
+	encoded, _ := json.Marshal(map[string]string{"error": err.Error()})
+	return C.CString(string(encoded))
+}
+
+// A c-shared library needs a main package, whose main is never run
+func main() {}
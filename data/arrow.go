@@ -0,0 +1,344 @@
+package data
+
+import (
+	"encoding/binary"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// A minimal Arrow IPC file writer, the format of Feather v2 that pandas and polars load without parsing: the rows
+// written are batched into uncompressed record batches of nullable columns. "NA", empty and, for the typed columns,
+// unparseable cells become nulls. Metadata is serialized as flatbuffers as described in
+// https://arrow.apache.org/docs/format/Columnar.html#ipc-file-format
+
+const arrowMagic = "ARROW1"
+
+// Types of the columns of an Arrow file
+const (
+	ArrowFloat64 = iota
+	ArrowInt64
+	ArrowBool
+	ArrowUtf8
+)
+
+// Arrow flatbuffer enum values used by the writer
+const (
+	arrowMetadataV5 = 4
+	arrowHeaderSchema = 1
+	arrowHeaderRecordBatch = 3
+	arrowTypeInt = 2
+	arrowTypeFloatingPoint = 3
+	arrowTypeUtf8 = 5
+	arrowTypeBool = 6
+	arrowPrecisionDouble = 2
+)
+
+// A column of an Arrow file
+type ArrowColumn struct {
+	Name string
+	Type int
+}
+
+// An Arrow IPC file being written. It is written under a temporary name until Commit, as an AtomicFile
+type ArrowFile struct {
+	file *AtomicFile
+	columns []ArrowColumn
+	offset int64
+	batches [][]byte //Block structs of the record batches, for the footer
+}
+
+// Creates an Arrow file with columns and writes its schema
+func CreateArrow(path string, columns []ArrowColumn) (*ArrowFile, error) {
+	file, err := CreateAtomic(path)
+	if err != nil {
+		return nil, err
+	}
+	f := &ArrowFile{file: file, columns: columns}
+	if err := f.write([]byte(arrowMagic + "\x00\x00")); err != nil {
+		file.Abort()
+		return nil, err
+	}
+	if _, err := f.writeMessage(arrowHeaderSchema, f.schema(), nil); err != nil {
+		file.Abort()
+		return nil, err
+	}
+	return f, nil
+}
+
+// Writes rows, cells in the order of the columns, as one record batch
+func (f *ArrowFile) WriteBatch(rows [][]string) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	var body []byte
+	var nodes, buffers []byte
+	addBuffer := func(buffer []byte) {
+		buffers = binary.LittleEndian.AppendUint64(buffers, uint64(len(body)))
+		buffers = binary.LittleEndian.AppendUint64(buffers, uint64(len(buffer)))
+		body = append(body, buffer...)
+		for len(body) % 8 != 0 {
+			body = append(body, 0)
+		}
+	}
+	for column, spec := range f.columns {
+		validity := make([]byte, (len(rows) + 7) / 8)
+		nullCount := 0
+		var values, data []byte
+		if spec.Type == ArrowBool {
+			values = make([]byte, (len(rows) + 7) / 8)
+		}
+		if spec.Type == ArrowUtf8 {
+			values = binary.LittleEndian.AppendUint32(values, 0)
+		}
+		for i, row := range rows {
+			cell := strings.TrimSpace(row[column])
+			valid := cell != "" && cell != "NA"
+			switch spec.Type {
+			case ArrowFloat64:
+				value, err := strconv.ParseFloat(cell, 64)
+				valid = valid && err == nil
+				values = binary.LittleEndian.AppendUint64(values, math.Float64bits(value))
+			case ArrowInt64:
+				value, err := strconv.ParseInt(cell, 10, 64)
+				valid = valid && err == nil
+				values = binary.LittleEndian.AppendUint64(values, uint64(value))
+			case ArrowBool:
+				value, err := strconv.ParseBool(cell)
+				valid = valid && err == nil
+				if value {
+					values[i / 8] |= 1 << (i % 8)
+				}
+			case ArrowUtf8:
+				if valid {
+					data = append(data, cell...)
+				}
+				values = binary.LittleEndian.AppendUint32(values, uint32(len(data)))
+			}
+			if valid {
+				validity[i / 8] |= 1 << (i % 8)
+			} else {
+				nullCount++
+			}
+		}
+		nodes = binary.LittleEndian.AppendUint64(nodes, uint64(len(rows)))
+		nodes = binary.LittleEndian.AppendUint64(nodes, uint64(nullCount))
+		addBuffer(validity)
+		addBuffer(values)
+		if spec.Type == ArrowUtf8 {
+			addBuffer(data)
+		}
+	}
+	batch := &flatTable{fields: []flatField{flatInt64(int64(len(rows))), flatRef(&flatStructs{nodes, 16}),
+		flatRef(&flatStructs{buffers, 16})}}
+	offset := f.offset
+	metadataLength, err := f.writeMessage(arrowHeaderRecordBatch, batch, body)
+	if err != nil {
+		return err
+	}
+	block := binary.LittleEndian.AppendUint64(nil, uint64(offset))
+	block = binary.LittleEndian.AppendUint32(block, uint32(metadataLength))
+	block = append(block, 0, 0, 0, 0)
+	f.batches = append(f.batches, binary.LittleEndian.AppendUint64(block, uint64(len(body))))
+	return nil
+}
+
+// Writes the end of stream marker and the footer, and renames the file into place. On error it is removed
+func (f *ArrowFile) Commit() error {
+	var blocks []byte
+	for _, block := range f.batches {
+		blocks = append(blocks, block...)
+	}
+	footer := buildFlatbuffer(&flatTable{fields: []flatField{flatInt16(arrowMetadataV5), flatRef(f.schema()),
+		flatRef(&flatStructs{nil, 24}), flatRef(&flatStructs{blocks, 24})}})
+	err := f.write([]byte{0xff, 0xff, 0xff, 0xff, 0, 0, 0, 0})
+	if err == nil {
+		err = f.write(binary.LittleEndian.AppendUint32(footer, uint32(len(footer))))
+	}
+	if err == nil {
+		err = f.write([]byte(arrowMagic))
+	}
+	if err != nil {
+		f.file.Abort()
+		return err
+	}
+	return f.file.Commit()
+}
+
+// Closes and removes the unfinished file
+func (f *ArrowFile) Abort() {
+	f.file.Abort()
+}
+
+// Describes the columns as an Arrow Schema table
+func (f *ArrowFile) schema() *flatTable {
+	fields := make(flatVector, len(f.columns))
+	for i, column := range f.columns {
+		var typeType int
+		var typeTable *flatTable
+		switch column.Type {
+		case ArrowFloat64:
+			typeType, typeTable = arrowTypeFloatingPoint, &flatTable{fields: []flatField{flatInt16(arrowPrecisionDouble)}}
+		case ArrowInt64:
+			typeType, typeTable = arrowTypeInt, &flatTable{fields: []flatField{flatInt32(64), flatBool(true)}}
+		case ArrowBool:
+			typeType, typeTable = arrowTypeBool, &flatTable{}
+		default:
+			typeType, typeTable = arrowTypeUtf8, &flatTable{}
+		}
+		fields[i] = &flatTable{fields: []flatField{flatRef(flatString(column.Name)), flatBool(true), flatUint8(uint8(typeType)),
+			flatRef(typeTable), {}, flatRef(flatVector{})}}
+	}
+	return &flatTable{fields: []flatField{flatInt16(0), flatRef(fields)}} //little endian
+}
+
+// Writes an encapsulated IPC message: a continuation marker, the length of the metadata, the Message flatbuffer
+// wrapping header, and the body. Returns the length of everything before the body
+func (f *ArrowFile) writeMessage(headerType int, header *flatTable, body []byte) (int, error) {
+	metadata := buildFlatbuffer(&flatTable{fields: []flatField{flatInt16(arrowMetadataV5), flatUint8(uint8(headerType)),
+		flatRef(header), flatInt64(int64(len(body)))}})
+	prefix := binary.LittleEndian.AppendUint32([]byte{0xff, 0xff, 0xff, 0xff}, uint32(len(metadata)))
+	for _, part := range [][]byte{prefix, metadata, body} {
+		if err := f.write(part); err != nil {
+			return 0, err
+		}
+	}
+	return len(prefix) + len(metadata), nil
+}
+
+func (f *ArrowFile) write(b []byte) error {
+	_, err := f.file.Write(b)
+	f.offset += int64(len(b))
+	return err
+}
+
+// A flatbuffer object: a table, a vector or a string, appended to the buffer by encode, which returns its position
+type flatObject interface {
+	encode(buffer []byte) ([]byte, int)
+}
+
+// A field of a table: an inline scalar of size bytes, a reference to a child object, or absent if both are zero
+type flatField struct {
+	size int
+	scalar uint64
+	child flatObject
+}
+
+func flatUint8(v uint8) flatField { return flatField{size: 1, scalar: uint64(v)} }
+func flatInt16(v int16) flatField { return flatField{size: 2, scalar: uint64(uint16(v))} }
+func flatInt32(v int32) flatField { return flatField{size: 4, scalar: uint64(uint32(v))} }
+func flatInt64(v int64) flatField { return flatField{size: 8, scalar: uint64(v)} }
+func flatRef(child flatObject) flatField { return flatField{size: 4, child: child} }
+
+func flatBool(v bool) flatField {
+	if v {
+		return flatUint8(1)
+	}
+	return flatUint8(0)
+}
+
+// Builds a flatbuffer front to back rather than back to front as the flatbuffers library does: every object is
+// appended before its children, so the references to them, which must point forward, are patched in once they follow.
+// The buffer is padded to a multiple of 8 bytes, as Arrow messages need
+func buildFlatbuffer(root flatObject) []byte {
+	buffer, position := root.encode(make([]byte, 4))
+	binary.LittleEndian.PutUint32(buffer, uint32(position))
+	return pad(buffer, 8)
+}
+
+func pad(buffer []byte, alignment int) []byte {
+	for len(buffer) % alignment != 0 {
+		buffer = append(buffer, 0)
+	}
+	return buffer
+}
+
+type flatTable struct {
+	fields []flatField
+}
+
+// Appends the vtable, then the table with its fields aligned to their size, then its children
+func (t *flatTable) encode(buffer []byte) ([]byte, int) {
+	offsets := make([]int, len(t.fields)) //of the fields in the table, 0 for absent ones
+	size, alignment := 4, 4 //the table starts with the offset to its vtable
+	for i, field := range t.fields {
+		if field.size == 0 {
+			continue
+		}
+		for size % field.size != 0 {
+			size++
+		}
+		offsets[i] = size
+		size += field.size
+		if field.size > alignment {
+			alignment = field.size
+		}
+	}
+	buffer = pad(buffer, 2)
+	vtable := len(buffer)
+	buffer = binary.LittleEndian.AppendUint16(buffer, uint16(4 + 2 * len(t.fields)))
+	buffer = binary.LittleEndian.AppendUint16(buffer, uint16(size))
+	for _, offset := range offsets {
+		buffer = binary.LittleEndian.AppendUint16(buffer, uint16(offset))
+	}
+	buffer = pad(buffer, alignment)
+	table := len(buffer)
+	buffer = append(buffer, make([]byte, size)...)
+	binary.LittleEndian.PutUint32(buffer[table:], uint32(table - vtable))
+	for i, field := range t.fields {
+		if field.size == 0 {
+			continue
+		}
+		if field.child == nil {
+			for b := 0; b < field.size; b++ {
+				buffer[table + offsets[i] + b] = byte(field.scalar >> (8 * b))
+			}
+			continue
+		}
+		var child int
+		buffer, child = field.child.encode(buffer)
+		binary.LittleEndian.PutUint32(buffer[table + offsets[i]:], uint32(child - table - offsets[i]))
+	}
+	return buffer, table
+}
+
+// A vector of references to tables or strings
+type flatVector []flatObject
+
+func (v flatVector) encode(buffer []byte) ([]byte, int) {
+	buffer = pad(buffer, 4)
+	vector := len(buffer)
+	buffer = binary.LittleEndian.AppendUint32(buffer, uint32(len(v)))
+	buffer = append(buffer, make([]byte, 4 * len(v))...)
+	for i, element := range v {
+		var child int
+		buffer, child = element.encode(buffer)
+		slot := vector + 4 + 4 * i
+		binary.LittleEndian.PutUint32(buffer[slot:], uint32(child - slot))
+	}
+	return buffer, vector
+}
+
+// A vector of structs of size bytes each, already laid out, aligned to 8 bytes as Arrow's structs need
+type flatStructs struct {
+	elements []byte
+	size int
+}
+
+func (v *flatStructs) encode(buffer []byte) ([]byte, int) {
+	for len(buffer) % 8 != 4 { //the elements after the length are 8 byte aligned
+		buffer = append(buffer, 0)
+	}
+	vector := len(buffer)
+	buffer = binary.LittleEndian.AppendUint32(buffer, uint32(len(v.elements) / v.size))
+	return append(buffer, v.elements...), vector
+}
+
+type flatString string
+
+func (s flatString) encode(buffer []byte) ([]byte, int) {
+	buffer = pad(buffer, 4)
+	position := len(buffer)
+	buffer = binary.LittleEndian.AppendUint32(buffer, uint32(len(s)))
+	return append(append(buffer, s...), 0), position
+}
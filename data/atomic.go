@@ -0,0 +1,59 @@
+package data
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// An output file written under a temporary name in the directory of its final path and renamed into place by
+// Commit, so a crash or failed write never leaves a partial file at the path that could pass for a complete one
+type AtomicFile struct {
+	*os.File
+	path string
+}
+
+// Creates the temporary file of an output file at path
+func CreateAtomic(path string) (*AtomicFile, error) {
+	file, err := os.CreateTemp(filepath.Dir(path), "." + filepath.Base(path) + ".tmp*")
+	if err != nil {
+		return nil, err
+	}
+	return &AtomicFile{File: file, path: path}, nil
+}
+
+// Syncs and closes the temporary file and renames it to its final path. On error the temporary file is removed
+func (f *AtomicFile) Commit() error {
+	err := f.Chmod(0644)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), f.path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// Closes and removes the temporary file, leaving whatever was at the final path untouched
+func (f *AtomicFile) Abort() {
+	f.Close()
+	os.Remove(f.Name())
+}
+
+// Writes content to path atomically
+func WriteFileAtomic(path string, content []byte) error {
+	file, err := CreateAtomic(path)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(content); err != nil {
+		file.Abort()
+		return err
+	}
+	return file.Commit()
+}
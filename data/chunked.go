@@ -0,0 +1,79 @@
+package data
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"log"
+	"math"
+)
+
+// Reads the x and y columns of a training data file, csv or gzip compressed csv, in chunks of at most chunkRows rows
+// and calls fn with each, so data larger than memory can be passed over without loading it. x is read from the first
+// column and y from the last, as by LoadTrainingData, and rows with an empty or unparseable x or y are dropped. The
+// slices of a chunk are reused by the next one, so fn must not keep them
+func ReadChunks(filename string, chunkRows int, fn func(chunk InputData)) {
+	if chunkRows < 1 {
+		chunkRows = 1
+	}
+	switch dataExtension(filename) {
+	case ".xlsx", ".jsonl", ".ndjson", ".libsvm", ".svm", ".svmlight":
+		log.Fatal("Error: ", filename, " is not a csv file, which reading in chunks needs")
+	}
+	chunk := InputData{X: make([]float64, 0, chunkRows), Y: make([]float64, 0, chunkRows)}
+	csvReader, closeFile, err := openCSV(filename)
+	if err != nil {
+		log.Fatal("Error: ", err)
+	}
+	defer closeFile()
+	csvReader.ReuseRecord = true
+	for {
+		line, err := csvReader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			log.Fatal("Error: issue with reading line from csv file ", filename, ": ", err)
+		}
+		x, y := parseCell(line[0]), parseCell(line[len(line) - 1])
+		if math.IsNaN(x) || math.IsNaN(y) {
+			continue
+		}
+		chunk.X, chunk.Y = append(chunk.X, x), append(chunk.Y, y)
+		if len(chunk.X) == chunkRows {
+			fn(chunk)
+			chunk.X, chunk.Y = chunk.X[:0], chunk.Y[:0]
+		}
+	}
+	if len(chunk.X) > 0 {
+		fn(chunk)
+	}
+}
+
+// Returns the Fingerprint of the x and y columns of a training data file as ReadChunks reads them, without loading the
+// file: it is read once to count its rows and once more per column. It matches the Fingerprint of the loaded file
+// when the file has no feature columns
+func FingerprintChunks(filename string, chunkRows int) string {
+	rows := 0
+	ReadChunks(filename, chunkRows, func(chunk InputData) { rows += len(chunk.X) })
+	hash := sha256.New()
+	buffer := make([]byte, 8)
+	write := func(value uint64) {
+		binary.LittleEndian.PutUint64(buffer, value)
+		hash.Write(buffer)
+	}
+	write(uint64(rows))
+	ReadChunks(filename, chunkRows, func(chunk InputData) {
+		for _, x := range chunk.X {
+			write(math.Float64bits(x))
+		}
+	})
+	write(uint64(rows))
+	ReadChunks(filename, chunkRows, func(chunk InputData) {
+		for _, y := range chunk.Y {
+			write(math.Float64bits(y))
+		}
+	})
+	return hex.EncodeToString(hash.Sum(nil))
+}
@@ -0,0 +1,34 @@
+package data
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Failures of loading data that library callers and the CLI can tell apart with errors.Is rather than by message
+var (
+	ErrEmptyData = errors.New("data: no rows")
+	ErrBadCSVRow = errors.New("data: bad csv row")
+	ErrBadSplit = errors.New("data: bad split fractions")
+	ErrUnreadableFormat = errors.New("data: cannot read") // a format the generator writes but the loaders do not read, eg .parquet
+)
+
+// A row of a data file that cannot be read, or that has a different number of columns than the first. It is
+// ErrBadCSVRow to errors.Is, and unwraps to the reader's own error
+type RowError struct {
+	Filename string
+	Row int // 1-based, counting any header row
+	Err error
+}
+
+func (e *RowError) Error() string {
+	return fmt.Sprintf("row %d of %s: %v", e.Row, e.Filename, e.Err)
+}
+
+func (e *RowError) Is(target error) bool {
+	return target == ErrBadCSVRow
+}
+
+func (e *RowError) Unwrap() error {
+	return e.Err
+}
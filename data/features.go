@@ -0,0 +1,51 @@
+package data
+
+import (
+	"sort"
+)
+
+// Returns a copy of d holding only the given rows, in the given order
+func SelectRows(d InputData, indices []int) InputData {
+	output := NewInputData(len(indices), len(d.Features))
+	output.FeatureNames = d.FeatureNames
+	for _, index := range indices {
+		output.X = append(output.X, d.X[index])
+		output.Y = append(output.Y, d.Y[index])
+	}
+	for i, feature := range d.Features {
+		for _, index := range indices {
+			output.Features[i] = append(output.Features[i], feature[index])
+		}
+	}
+	return output
+}
+
+// One-hot encodes a categorical column. Levels are sorted and the first one is the reference level, which gets no
+// column of its own so the encoded columns are not collinear with the intercept. An empty cell is missing and encodes
+// as 0 in every column. Returns one 0/1 column per remaining level and the level names
+func OneHot(values []string) ([][]float64, []string) {
+	seen := make(map[string]bool)
+	levels := make([]string, 0)
+	for _, value := range values {
+		if value != "" && !seen[value] {
+			seen[value] = true
+			levels = append(levels, value)
+		}
+	}
+	sort.Strings(levels)
+	if len(levels) > 0 {
+		levels = levels[1:]
+	}
+	columnOf := make(map[string]int)
+	columns := make([][]float64, len(levels))
+	for i, level := range levels {
+		columnOf[level] = i
+		columns[i] = make([]float64, len(values))
+	}
+	for row, value := range values {
+		if column, ok := columnOf[value]; ok {
+			columns[column][row] = 1
+		}
+	}
+	return columns, levels
+}
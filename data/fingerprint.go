@@ -0,0 +1,30 @@
+package data
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"math"
+)
+
+// Returns a SHA-256 content hash of the data as loaded, covering every value of x, the features and y in order and the
+// feature names. Two datasets have the same fingerprint exactly when a search would see the same rows
+func Fingerprint(d InputData) string {
+	hash := sha256.New()
+	buffer := make([]byte, 8)
+	writeColumn := func(column []float64) {
+		binary.LittleEndian.PutUint64(buffer, uint64(len(column)))
+		hash.Write(buffer)
+		for _, value := range column {
+			binary.LittleEndian.PutUint64(buffer, math.Float64bits(value))
+			hash.Write(buffer)
+		}
+	}
+	writeColumn(d.X)
+	for i, feature := range d.Features {
+		hash.Write([]byte(d.FeatureNames[i]))
+		writeColumn(feature)
+	}
+	writeColumn(d.Y)
+	return hex.EncodeToString(hash.Sum(nil))
+}
@@ -0,0 +1,124 @@
+package data
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/csv"
+	"log"
+	"path/filepath"
+	"strings"
+)
+
+// File formats the generator can write, selected by the output file extension
+const (
+	formatCSV = iota
+	formatCSVGzip
+	formatParquet
+)
+
+// Returns the extension of a data file, treating compound extensions like .csv.gz as one extension
+func fileExtension(path string) string {
+	if strings.HasSuffix(path, ".csv.gz") {
+		return ".csv.gz"
+	}
+	return filepath.Ext(path)
+}
+
+// Picks the output format for a file path: .csv.gz is gzip compressed csv, .parquet is parquet and anything else is csv
+func formatFromPath(path string) int {
+	switch fileExtension(path) {
+	case ".csv.gz":
+		return formatCSVGzip
+	case ".parquet":
+		return formatParquet
+	default:
+		return formatCSV
+	}
+}
+
+// A chunk of rows encoded in the format of the file it will be written to. columns is only set for parquet, where each
+// chunk becomes one row group and the footer needs to know where its column chunks ended up
+type encodedChunk struct {
+	bytes []byte
+	numRows int
+	columns []parquetColumnChunk
+}
+
+// A generator output file. Chunks are encoded by the generator workers and written in order by the merger
+type generatedFile struct {
+	file *AtomicFile
+	format int
+	offset int64
+	rowGroups []parquetRowGroup
+	columnNames []string
+}
+
+func createGeneratedFile(path string) *generatedFile {
+	file, err := CreateAtomic(path)
+	if err!= nil {
+		log.Fatal("Error: could not create file")
+	}
+	output := &generatedFile{file: file, format: formatFromPath(path)}
+	if output.format == formatParquet {
+		output.writeBytes([]byte(parquetMagic))
+	}
+	return output
+}
+
+// Encodes rows in the file's format. Safe to call from several goroutines at once. Gzip chunks are compressed as
+// independent gzip members, which concatenate into a valid multistream gzip file
+func (output *generatedFile) encode(rows [][]string) encodedChunk {
+	if output.format == formatParquet {
+		return encodeParquetRowGroup(rows)
+	}
+	var buffer bytes.Buffer
+	var writer *csv.Writer
+	var gzipWriter *gzip.Writer
+	if output.format == formatCSVGzip {
+		gzipWriter = gzip.NewWriter(&buffer)
+		writer = csv.NewWriter(gzipWriter)
+	} else {
+		writer = csv.NewWriter(&buffer)
+	}
+	for _, row := range rows {
+		err := writer.Write(row)
+		if err != nil {
+			log.Fatal("Error: trouble writing to file")
+		}
+	}
+	writer.Flush()
+	if gzipWriter != nil {
+		gzipWriter.Close()
+	}
+	return encodedChunk{bytes: buffer.Bytes(), numRows: len(rows)}
+}
+
+// Appends an encoded chunk to the file. Only called from the merger goroutine
+func (output *generatedFile) write(chunk encodedChunk) {
+	if chunk.numRows == 0 {
+		return
+	}
+	if output.format == formatParquet {
+		output.addRowGroup(chunk)
+	}
+	output.writeBytes(chunk.bytes)
+}
+
+func (output *generatedFile) writeBytes(b []byte) {
+	_, err := output.file.Write(b)
+	if err != nil {
+		output.file.Abort()
+		log.Fatal("Error: trouble writing to file")
+	}
+	output.offset += int64(len(b))
+}
+
+// Finishes the file and renames it into place, as it is written under a temporary name until then
+func (output *generatedFile) Close() {
+	if output.format == formatParquet {
+		output.writeBytes(output.parquetFooter())
+	}
+	if output.file.Commit() != nil {
+		log.Fatal("Error: trouble writing to file")
+	}
+}
@@ -0,0 +1,217 @@
+package data
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// A ground truth y = f(x) parsed from an expression like "5*x + 100 + sin(x)"
+type Formula func(x float64) float64
+
+// Functions a formula can call
+var formulaFunctions = map[string]func(float64) float64{
+	"sin": math.Sin, "cos": math.Cos, "tan": math.Tan, "exp": math.Exp, "log": math.Log,
+	"sqrt": math.Sqrt, "abs": math.Abs,
+}
+
+// Parses a formula of the variable x with + - * / ^ (power), parentheses, unary minus, numbers, the constants pi and e
+// and the functions in formulaFunctions. Operators have the usual precedence and ^ is right associative
+func ParseFormula(expression string) (Formula, error) {
+	parser := &formulaParser{input: expression}
+	formula, err := parser.parseSum()
+	if err != nil {
+		return nil, err
+	}
+	parser.skipSpace()
+	if parser.position < len(parser.input) {
+		return nil, fmt.Errorf("unexpected %q at position %d of formula", parser.input[parser.position:], parser.position)
+	}
+	return formula, nil
+}
+
+// A recursive descent parser, one method per precedence level
+type formulaParser struct {
+	input string
+	position int
+}
+
+func (p *formulaParser) skipSpace() {
+	for p.position < len(p.input) && p.input[p.position] == ' ' {
+		p.position++
+	}
+}
+
+// Consumes op if it is the next non-space character
+func (p *formulaParser) accept(op byte) bool {
+	p.skipSpace()
+	if p.position < len(p.input) && p.input[p.position] == op {
+		p.position++
+		return true
+	}
+	return false
+}
+
+// Returns the length of an exponent suffix like e5, E+5 or e-5 at the current position, or 0 if there is none
+func (p *formulaParser) exponentLength() int {
+	rest := p.input[p.position:]
+	if len(rest) < 2 || (rest[0] != 'e' && rest[0] != 'E') {
+		return 0
+	}
+	length := 1
+	if rest[1] == '+' || rest[1] == '-' {
+		length++
+	}
+	digits := 0
+	for length + digits < len(rest) && unicode.IsDigit(rune(rest[length + digits])) {
+		digits++
+	}
+	if digits == 0 {
+		return 0
+	}
+	return length + digits
+}
+
+// sum = product { ("+" | "-") product }
+func (p *formulaParser) parseSum() (Formula, error) {
+	left, err := p.parseProduct()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		var add bool
+		if p.accept('+') {
+			add = true
+		} else if !p.accept('-') {
+			return left, nil
+		}
+		right, err := p.parseProduct()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		if add {
+			left = func(x float64) float64 { return l(x) + right(x) }
+		} else {
+			left = func(x float64) float64 { return l(x) - right(x) }
+		}
+	}
+}
+
+// product = unary { ("*" | "/") unary }
+func (p *formulaParser) parseProduct() (Formula, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		var multiply bool
+		if p.accept('*') {
+			multiply = true
+		} else if !p.accept('/') {
+			return left, nil
+		}
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		if multiply {
+			left = func(x float64) float64 { return l(x) * right(x) }
+		} else {
+			left = func(x float64) float64 { return l(x) / right(x) }
+		}
+	}
+}
+
+// unary = "-" unary | power
+func (p *formulaParser) parseUnary() (Formula, error) {
+	if p.accept('-') {
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return func(x float64) float64 { return -operand(x) }, nil
+	}
+	return p.parsePower()
+}
+
+// power = atom [ "^" unary ]
+func (p *formulaParser) parsePower() (Formula, error) {
+	base, err := p.parseAtom()
+	if err != nil {
+		return nil, err
+	}
+	if !p.accept('^') {
+		return base, nil
+	}
+	exponent, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	return func(x float64) float64 { return math.Pow(base(x), exponent(x)) }, nil
+}
+
+// atom = number | "x" | "pi" | "e" | function "(" sum ")" | "(" sum ")"
+func (p *formulaParser) parseAtom() (Formula, error) {
+	p.skipSpace()
+	if p.position >= len(p.input) {
+		return nil, fmt.Errorf("formula ends unexpectedly")
+	}
+	if p.accept('(') {
+		inner, err := p.parseSum()
+		if err != nil {
+			return nil, err
+		}
+		if !p.accept(')') {
+			return nil, fmt.Errorf("missing ) at position %d of formula", p.position)
+		}
+		return inner, nil
+	}
+
+	start := p.position
+	if c := rune(p.input[p.position]); unicode.IsDigit(c) || c == '.' {
+		for p.position < len(p.input) && (unicode.IsDigit(rune(p.input[p.position])) || p.input[p.position] == '.') {
+			p.position++
+		}
+		if exponentLength := p.exponentLength(); exponentLength > 0 { //scientific notation, eg 1e-3
+			p.position += exponentLength
+		}
+		value, err := strconv.ParseFloat(p.input[start:p.position], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q in formula", p.input[start:p.position])
+		}
+		return func(x float64) float64 { return value }, nil
+	}
+	for p.position < len(p.input) && unicode.IsLetter(rune(p.input[p.position])) {
+		p.position++
+	}
+	name := strings.ToLower(p.input[start:p.position])
+	switch name {
+	case "":
+		return nil, fmt.Errorf("unexpected %q at position %d of formula", p.input[start:start + 1], start)
+	case "x":
+		return func(x float64) float64 { return x }, nil
+	case "pi":
+		return func(x float64) float64 { return math.Pi }, nil
+	case "e":
+		return func(x float64) float64 { return math.E }, nil
+	}
+	function, ok := formulaFunctions[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown name %q in formula", name)
+	}
+	if !p.accept('(') {
+		return nil, fmt.Errorf("missing ( after %s in formula", name)
+	}
+	argument, err := p.parseSum()
+	if err != nil {
+		return nil, err
+	}
+	if !p.accept(')') {
+		return nil, fmt.Errorf("missing ) at position %d of formula", p.position)
+	}
+	return func(x float64) float64 { return function(argument(x)) }, nil
+}
@@ -1,71 +1,688 @@
 package data
 
 import (
+	"compress/gzip"
 	"encoding/csv"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"math"
 	"math/rand"
 	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
+	"sync"
 )
 
-// Generates data of sample size n, where the dependent variable is simply the independent variable * 5 + 100 + noise
-func GenerateTrainingData(n int, outputFilePath string){
+// Number of rows each generator worker produces at a time. Chunks are merged back into the output file in order
+const generateChunkSize = 100000
+
+// Settings shared by the data generators
+type GenerateOptions struct {
+	NumWorkers int // number of goroutines generating chunks in parallel
+	Holdout float64 // fraction of rows written to a separate test file drawn from the same ground truth
+	Rho float64 // AR(1) coefficient of the residuals, only used by GenerateTimeSeriesData
+	Ordered bool // keep time series rows in time order, only used by GenerateTimeSeriesData
+	MissingX float64 // fraction of x cells left empty
+	MissingY float64 // fraction of y cells left empty
+	Collinear int // number of extra, nearly collinear copies of x written before y, only used by GenerateTrainingData. The
+	              // columns are written for other tools, the search fits y on x alone
+	Categories int // number of levels of a categorical column written before y, each shifting y by categoryEffect. Only used by GenerateTrainingData.
+	               // Levels are the integers 0..Categories-1, so the column stays numeric. The search fits y on x alone, so the
+	               // shift is noise to it, even once -categorical one-hot encodes the column
+	Seed int64 // seed of the generator, so the same options always produce the same file. 0 draws a random seed
+	XDist string // distribution x is drawn from: "uniform" (default), "normal" or "lognormal"
+	XParams []float64 // parameters of XDist: min and max for uniform, mean and standard deviation for normal, and the mean and
+	                  // standard deviation of log(x) for lognormal. Defaults to uniform(0, 100)
+	XInteger bool // round x to the nearest integer, eg for counts
+	Formula string // ground truth of y as an expression of x, eg "5*x + 100 + sin(x)", replacing the default 5*x + 100. Only used by GenerateTrainingData
+	XLevels int // draw x uniformly from the integers 0..XLevels-1 instead of XDist, eg for categories encoded as ints
+}
+
+// Draws an x value from the configured distribution
+func (opts GenerateOptions) sampleX(rng *rand.Rand) float64 {
+	if opts.XLevels > 0 {
+		return float64(rng.Intn(opts.XLevels))
+	}
+	x := opts.sampleContinuousX(rng)
+	if opts.XInteger {
+		return math.Round(x)
+	}
+	return x
+}
+
+func (opts GenerateOptions) sampleContinuousX(rng *rand.Rand) float64 {
+	a, b := float64(0), float64(100)
+	if len(opts.XParams) == 2 {
+		a, b = opts.XParams[0], opts.XParams[1]
+	}
+	switch opts.XDist {
+	case "normal":
+		return rng.NormFloat64() * b + a
+	case "lognormal":
+		return math.Exp(rng.NormFloat64() * b + a)
+	default:
+		return a + rng.Float64() * (b - a)
+	}
+}
+
+// Checks the x distribution settings, so a typo fails before any file is written
+func (opts GenerateOptions) validateX() {
+	switch opts.XDist {
+	case "", "uniform", "normal", "lognormal":
+	default:
+		log.Fatal("Error: unknown x distribution ", opts.XDist)
+	}
+	if len(opts.XParams) != 0 && len(opts.XParams) != 2 {
+		log.Fatal("Error: x distribution takes exactly two parameters")
+	}
+	if len(opts.XParams) == 2 && opts.XDist != "" && opts.XDist != "uniform" && opts.XParams[1] < 0 {
+		log.Fatal("Error: standard deviation of the x distribution must not be negative")
+	}
+}
+
+// Returns the random source a generator run draws from, seeded with opts.Seed when one is given
+func (opts GenerateOptions) random() *rand.Rand {
+	if opts.Seed == 0 {
+		return rand.New(rand.NewSource(rand.Int63()))
+	}
+	return rand.New(rand.NewSource(opts.Seed))
+}
+
+// Standard deviation of the noise separating a collinear feature from x. Small relative to the x range of 100, so
+// the features are highly but not perfectly correlated
+const collinearNoise = float64(0.5)
+
+// Shift of y between consecutive levels of a generated categorical column
+const categoryEffect = float64(20)
+
+// Blanks out cells of a generated row with the configured missing value fractions. y is always the last column
+func injectMissing(row []string, opts GenerateOptions, rng *rand.Rand) []string {
+	if opts.MissingX > 0 && rng.Float64() < opts.MissingX {
+		row[0] = ""
+	}
+	if opts.MissingY > 0 && rng.Float64() < opts.MissingY {
+		row[len(row) - 1] = ""
+	}
+	return row
+}
+
+// Returns the train and test file paths written when a holdout fraction is requested, eg data.csv -> data_train.csv, data_test.csv
+func HoldoutPaths(outputFilePath string) (string, string) {
+	extension := fileExtension(outputFilePath)
+	base := strings.TrimSuffix(outputFilePath, extension)
+	return base + "_train" + extension, base + "_test" + extension
+}
+
+// Generates data of sample size n, where the dependent variable is simply the independent variable * 5 + 100 + noise,
+// or opts.Formula + noise when a formula is given. Rows are generated in chunks by opts.NumWorkers goroutines and
+// written to a single file in order
+func GenerateTrainingData(n int, outputFilePath string, opts GenerateOptions){
 	trueBeta := float64(5)
 	trueMu := float64(100)
 	trueErrorVariance := float64(25)
+	groundTruth := Formula(func(x float64) float64 { return trueBeta * x + trueMu })
+	if opts.Formula != "" {
+		formula, err := ParseFormula(opts.Formula)
+		if err != nil {
+			log.Fatal("Error: ", err)
+		}
+		groundTruth = formula
+	}
 	fmt.Println("Generating data into", outputFilePath)
-	file, err := os.Create(outputFilePath)
-	if err!= nil {
-		log.Fatal("Error: could not create file")
+	generateParallel(n, outputFilePath, opts, func(rng *rand.Rand, i int) []string {
+		x := opts.sampleX(rng)
+		noise := rng.NormFloat64() * trueErrorVariance + 0 // randomly drawing error from ~N(0, trueErrorVariance)
+		y := groundTruth(x) + noise
+		row := []string{ fmt.Sprintf("%f", x)}
+		for j := 0; j < opts.Collinear; j++ { // copies of x plus a little noise, which do not enter the true model
+			row = append(row, fmt.Sprintf("%f", x + rng.NormFloat64() * collinearNoise))
+		}
+		if opts.Categories > 0 {
+			level := rng.Intn(opts.Categories)
+			y += categoryEffect * float64(level)
+			row = append(row, strconv.Itoa(level)) //an integer code, which numeric readers such as .parquet files keep
+		}
+		return append(row, fmt.Sprintf("%f", y))
+	})
+}
+
+// Generates binary labelled data of sample size n, where the dependent variable is drawn from a logistic model
+// P(y=1|x) = 1 / (1 + exp(-(0.1 * x - 5))), so the decision boundary sits in the middle of the x range at x = 50
+func GenerateClassificationData(n int, outputFilePath string, opts GenerateOptions){
+	trueBeta := float64(0.1)
+	trueMu := float64(-5)
+	fmt.Println("Generating classification data into", outputFilePath)
+	generateParallel(n, outputFilePath, opts, func(rng *rand.Rand, i int) []string {
+		x := opts.sampleX(rng)
+		probability := 1 / (1 + math.Exp(-(trueBeta * x + trueMu)))
+		y := 0
+		if rng.Float64() < probability { // draw the label from ~Bernoulli(probability)
+			y = 1
+		}
+		return []string{ fmt.Sprintf("%f", x), strconv.Itoa(y)}
+	})
+}
+
+// Output files of a generator. test is nil unless a holdout fraction was requested, in which case rows at index
+// numTrain and above are written to it instead of train
+type generatedOutput struct {
+	train *generatedFile
+	test *generatedFile
+	numTrain int
+}
+
+// Creates the output file(s) for a generator run of n rows
+func createGeneratedOutput(n int, outputFilePath string, holdout float64) generatedOutput {
+	if holdout < 0 || holdout >= 1 {
+		log.Fatal("Error: holdout fraction must be in [0, 1)")
+	}
+	if holdout == 0 {
+		return generatedOutput{createGeneratedFile(outputFilePath), nil, n}
+	}
+	trainPath, testPath := HoldoutPaths(outputFilePath)
+	return generatedOutput{createGeneratedFile(trainPath), createGeneratedFile(testPath), n - int(math.Round(holdout * float64(n)))}
+}
+
+func (output generatedOutput) Close() {
+	output.train.Close()
+	if output.test != nil {
+		output.test.Close()
 	}
-	defer file.Close()
-	writer := csv.NewWriter(file)
-	defer writer.Flush()
+}
 
+// A chunk of encoded rows produced by a generator worker, split by which output file they belong to
+type generatedChunk struct {
+	index int
+	train encodedChunk
+	test encodedChunk
+}
+
+// Generates n rows with rowFunc in chunks of generateChunkSize. Workers each render a chunk into memory with their own
+// seeded random source, and the calling goroutine acts as the merger which writes chunks to the output file in chunk order.
+// The dispatcher hands out at most 2 * NumWorkers chunks that have not been written yet, which bounds memory use
+func generateParallel(n int, outputFilePath string, opts GenerateOptions, rowFunc func(rng *rand.Rand, i int) []string){
+	numWorkers := opts.NumWorkers
+	if numWorkers < 1 {
+		numWorkers = 1
+	}
+	opts.validateX()
+	output := createGeneratedOutput(n, outputFilePath, opts.Holdout)
+	defer output.Close()
+
+	numChunks := (n + generateChunkSize - 1) / generateChunkSize
+	seeds := make([]int64, numChunks) // drawn up front so the output only depends on the seed, not on scheduling
+	random := opts.random()
+	for i := range seeds {
+		seeds[i] = random.Int63()
+	}
+	chunkTasks := make(chan int)
+	chunkResults := make(chan generatedChunk, numWorkers)
+	inFlight := make(chan bool, 2 * numWorkers)
+
+	go func() { //dispatcher
+		for i := 0; i < numChunks; i++ {
+			inFlight <- true
+			chunkTasks <- i
+		}
+		close(chunkTasks)
+	}()
+	var group sync.WaitGroup
+	for w := 0; w < numWorkers; w++ {
+		group.Add(1)
+		go func() {
+			defer group.Done()
+			for index := range chunkTasks {
+				rng := rand.New(rand.NewSource(seeds[index]))
+				start := index * generateChunkSize
+				end := start + generateChunkSize
+				if end > n {
+					end = n
+				}
+				trainRows := make([][]string, 0, end - start)
+				testRows := make([][]string, 0)
+				for i := start; i < end; i++ {
+					row := injectMissing(rowFunc(rng, i), opts, rng)
+					if i >= output.numTrain {
+						testRows = append(testRows, row)
+					} else {
+						trainRows = append(trainRows, row)
+					}
+				}
+				chunk := generatedChunk{index: index, train: output.train.encode(trainRows)}
+				if output.test != nil {
+					chunk.test = output.test.encode(testRows)
+				}
+				chunkResults <- chunk
+			}
+		}()
+	}
+	go func() {
+		group.Wait()
+		close(chunkResults)
+	}()
+
+	//merger: buffer out of order chunks until the next chunk in sequence arrives
+	pending := make(map[int]generatedChunk)
+	next := 0
+	for chunk := range chunkResults {
+		pending[chunk.index] = chunk
+		for chunk, ok := pending[next]; ok; chunk, ok = pending[next] {
+			output.train.write(chunk.train)
+			if output.test != nil {
+				output.test.write(chunk.test)
+			}
+			delete(pending, next)
+			next++
+			<- inFlight
+		}
+	}
+}
+
+// Generates data of sample size n from the same linear model as GenerateTrainingData, but with AR(1) residuals
+// e_t = rho * e_(t-1) + innovation, scaled so the residuals keep the same marginal variance as the iid generator.
+// Rows are shuffled before writing unless opts.Ordered is true, in which case row order is time order and a holdout
+// test file holds the last rows in time. The series itself is sequential, so only the encoding runs in parallel
+func GenerateTimeSeriesData(n int, outputFilePath string, opts GenerateOptions){
+	trueBeta := float64(5)
+	trueMu := float64(100)
+	trueErrorVariance := float64(25)
+	rho := opts.Rho
+	if rho <= -1 || rho >= 1 {
+		log.Fatal("Error: AR(1) coefficient must be strictly between -1 and 1")
+	}
+	fmt.Println("Generating time series data into", outputFilePath)
+	opts.validateX()
+
+	random := opts.random()
+	opts.Seed = random.Int63() //seeds the chunk encoders, which only draw missing values
+	rows := make([][]string, 0, n)
+	innovationScale := math.Sqrt(1 - rho * rho)
+	noise := random.NormFloat64() * trueErrorVariance // start from the stationary distribution
 	for i:=0; i < n; i++ {
-		x := rand.Float64() * float64(100)
-		noise := rand.NormFloat64() * trueErrorVariance + 0 // randomly drawing error from ~N(0, trueErrorVariance)
-		y := trueBeta * x + trueMu + noise
-		row := []string{ fmt.Sprintf("%f", x),fmt.Sprintf("%f", y)}
-		err := writer.Write(row)
-		if err != nil {
-			log.Fatal("Error: trouble writing to file")
+		if i > 0 {
+			noise = rho * noise + random.NormFloat64() * trueErrorVariance * innovationScale
 		}
+		x := opts.sampleX(random)
+		y := trueBeta * x + trueMu + noise
+		rows = append(rows, []string{ fmt.Sprintf("%f", x),fmt.Sprintf("%f", y)})
 	}
+	if !opts.Ordered {
+		random.Shuffle(len(rows), func(i, j int) { rows[i], rows[j] = rows[j], rows[i] })
+	}
+	generateParallel(n, outputFilePath, opts, func(rng *rand.Rand, i int) []string {
+		return rows[i]
+	})
+}
+
+// Settings controlling how training data is loaded
+type LoadOptions struct {
+	Missing string // strategy for empty or unparseable cells: "drop" (default) removes the row, "mean" imputes the column mean, "keep" leaves them as NaN
+	Categorical []int // indexes of csv columns holding categories, which are one-hot encoded into Features
+	MaxBytes int64 // memory budget of the loaded data and the scaled copy a search trains on, 0 for none. Loading stops with an error once it is exceeded
+	Reservoir int // if > 0, keep a uniform random sample of this many rows, drawn while reading, so a file too big for memory can be searched
+	Seed int64 // seed of the Reservoir sample, 0 for random
+	Target string // header name of the y column of a file whose first row names its columns, "" for headerless files whose last column is y
+	Features []string // header names of the x column and then the Features columns with Target, all other columns in file order if empty. Only x is fit on
+	Sheet string // sheet of an .xlsx workbook to load, its first sheet if ""
 }
 
-// Member variables represent independent (x) and dependent (y) variables
-type InputData struct {
-	X []float64
-	Y []float64
+// Whether rows loaded rows of numColumns columns exceed the memory budget. Each row takes 8 bytes per numeric cell, a
+// string header per categorical one, and as much again for the scaled copy a search trains on
+func (opts LoadOptions) budgetExceeded(rows int, numColumns int) bool {
+	rowBytes := int64(8 * numColumns + 16 * len(opts.Categorical))
+	return opts.MaxBytes > 0 && 2 * int64(rows) * rowBytes > opts.MaxBytes
 }
 
-// loads in training data from csv file
-func LoadTrainingData(filename string) InputData{
-	xVector := make([] float64,0)
-	yVector := make([] float64,0)
-	csvFile, err := os.Open(filename)
+// loads in training data from csv file, gzip compressed csv file if the filename ends in .gz, the opts.Sheet of an
+// Excel workbook if it ends in .xlsx, or a JSON Lines file if it ends in .jsonl or .ndjson, whose objects are read by
+// their x and y fields or, with opts.Target, as a header of field names and their values, or a sparse LIBSVM file if it
+// ends in .libsvm, .svm or .svmlight, whose label is y and whose features are densified by index, absent ones 0, with
+// opts.Target "label" and opts.Features indexes picking them by name. x is read from the first column and y from the last, so files with extra feature
+// columns still load; those columns are read into Features, one-hot encoding the opts.Categorical ones. With
+// opts.Target, the first row is a header and the columns are picked by name instead: y is Target, x the first of
+// opts.Features and Features the rest, named by their headers, so wide files load without being sliced first. The search
+// fits y on x alone, so Features are carried along for describe and split but never fit on. Empty or unparseable
+// cells are treated as missing and handled with opts.Missing. Only a reservoir sample of opts.Reservoir rows is kept if
+// it is set, and loading stops with an error if the data would exceed the opts.MaxBytes memory budget. The count, min,
+// max, mean and variance of every column are gathered into Stats as rows are read, so scaling and describe need no
+// further pass: of the rows kept by the drop strategy, and of the observed values otherwise. With opts.Reservoir they
+// are of every row read rather than of the sample. The columns of a plain csv are sized for the whole file once its
+// first rows show how many bytes a row takes, rather than grown as it is read. Any error stops the program; library
+// callers that handle them use ReadTrainingData
+func LoadTrainingData(filename string, opts LoadOptions) InputData{
+	loaded, err := ReadTrainingData(filename, opts)
 	if err != nil {
-		log.Fatal("Error: issue with opening csv file")
+		log.Fatal("Error: ", err)
 	}
+	return loaded
+}
 
-	csvReader := csv.NewReader(csvFile)
+// Loads training data as LoadTrainingData does, returning what goes wrong rather than stopping the program: a
+// *RowError, which is ErrBadCSVRow, for a row that cannot be read, and ErrEmptyData if no rows are left to train on
+func ReadTrainingData(filename string, opts LoadOptions) (InputData, error) {
+	switch opts.Missing {
+	case "", "drop", "mean", "keep":
+	default:
+		return InputData{}, errors.New("data: unknown missing value strategy " + opts.Missing)
+	}
+	xVector := make([] float64,0, opts.Reservoir)
+	yVector := make([] float64,0, opts.Reservoir)
+	numericColumns := make(map[int][]float64)
+	categoricalColumns := make(map[int][]string)
+	isCategorical := make(map[int]bool)
+	for _, column := range opts.Categorical {
+		isCategorical[column] = true
+	}
+	numColumns := 0
+	rowsRead := 0
+	var rng *rand.Rand
+	if opts.Reservoir > 0 {
+		seed := opts.Seed
+		if seed == 0 {
+			seed = rand.Int63()
+		}
+		rng = rand.New(rand.NewSource(seed))
+	}
+	var values []float64 //cells of the current row, NaN for categorical ones
+	var stats []ColumnStats //of the numeric columns of the rows, by their position in a row
+	dropIncomplete := opts.Missing == "" || opts.Missing == "drop"
+	csvReader, closeFile, err := openRows(filename, opts)
+	if err != nil {
+		return InputData{}, err
+	}
+	defer closeFile()
+	var selected []int //csv columns read, x first and y last, when picked by name
+	var header []string
+	headerRows := 0
+	if opts.Target != "" {
+		headerRows = 1
+		if header, err = csvReader.Read(); err != nil {
+			return InputData{}, &RowError{filename, 1, fmt.Errorf("cannot read the header: %v", err)}
+		}
+		if selected, err = selectColumns(header, opts.Target, opts.Features, filename); err != nil {
+			return InputData{}, err
+		}
+		isCategorical = make(map[int]bool) //Categorical indexes name csv columns, which now sit at their position in selected
+		for position, column := range selected {
+			for _, categoricalColumn := range opts.Categorical {
+				isCategorical[position] = isCategorical[position] || categoricalColumn == column
+			}
+		}
+	}
 	for {
 		line, err := csvReader.Read()
 		if err == io.EOF{
 			break
 		}
+		var rowErr *RowError
+		if errors.As(err, &rowErr) { //a reader that knows the line of the file it failed on
+			return InputData{}, err
+		}
 		if err != nil {
-			log.Fatal("Error: issue with reading line from csv file", line)
+			return InputData{}, &RowError{filename, rowsRead + headerRows + 1, err}
 		}
 
-		x,_ := strconv.ParseFloat(line[0], 64)
-		y,_ := strconv.ParseFloat(line[1], 64)
+		if numColumns == 0 {
+			numColumns = len(line)
+		} else if len(line) != numColumns {
+			return InputData{}, &RowError{filename, rowsRead + headerRows + 1,
+				fmt.Errorf("%d columns rather than the %d of the first row", len(line), numColumns)}
+		}
+		if selected != nil {
+			line = pickColumns(line, selected)
+		}
+		rowsRead++
+		if values == nil {
+			values, stats = make([]float64, len(line)), make([]ColumnStats, len(line))
+		}
+		for column, cell := range line {
+			values[column] = math.NaN()
+			if !isCategorical[column] || column == 0 || column == len(line) - 1 {
+				values[column] = parseCell(cell)
+			}
+		}
+		addRowStats(stats, values, isCategorical, dropIncomplete)
+		last := len(line) - 1
+		if rng != nil && len(xVector) >= opts.Reservoir { //the sample is full: the row replaces a random one, with probability Reservoir/rowsRead
+			if i := rng.Intn(rowsRead); i < opts.Reservoir {
+				xVector[i], yVector[i] = values[0], values[last]
+				for column := 1; column < last; column++ {
+					if isCategorical[column] {
+						categoricalColumns[column][i] = strings.TrimSpace(line[column])
+					} else {
+						numericColumns[column][i] = values[column]
+					}
+				}
+			}
+			continue
+		}
+		xVector = append(xVector, values[0])
+		yVector = append(yVector, values[last])
+		for column := 1; column < last; column++ {
+			if isCategorical[column] {
+				categoricalColumns[column] = append(categoricalColumns[column], strings.TrimSpace(line[column]))
+			} else {
+				numericColumns[column] = append(numericColumns[column], values[column])
+			}
+		}
+		if opts.budgetExceeded(len(xVector), numColumns) {
+			return InputData{}, fmt.Errorf("data: %s needs more than the memory budget of %d MiB once loaded, at row %d; search on a sample drawn while loading instead",
+				filename, opts.MaxBytes >> 20, len(xVector))
+		}
+		if rowsRead == sizingRows && rng == nil { //size the columns for the whole file once, rather than growing them row by row
+			if rows := estimateRows(csvReader, filename, rowsRead); rows > len(xVector) && !opts.budgetExceeded(rows, numColumns) {
+				xVector, yVector = growColumn(xVector, rows), growColumn(yVector, rows)
+				for column := range numericColumns {
+					numericColumns[column] = growColumn(numericColumns[column], rows)
+				}
+				for column, cells := range categoricalColumns {
+					categoricalColumns[column] = append(make([]string, 0, rows), cells...)
+				}
+			}
+		}
+	}
+	loaded := InputData{X: xVector, Y: yVector}
+	if selected != nil {
+		numColumns = len(selected)
+	}
+	loadedStats := &DataStats{}
+	if stats != nil {
+		loadedStats.X, loadedStats.Y = stats[0], stats[len(stats) - 1]
+	}
+	var oneHot []int //features that are one-hot columns, whose statistics are taken once they are encoded
+	for column := 1; column < numColumns - 1; column++ {
+		numericName, categoricalName := "x" + strconv.Itoa(column), "c" + strconv.Itoa(column)
+		if selected != nil { //named by their header rather than their csv index
+			numericName, categoricalName = header[selected[column]], header[selected[column]]
+		}
+		if isCategorical[column] {
+			encoded, levels := OneHot(categoricalColumns[column])
+			for i, level := range levels {
+				oneHot = append(oneHot, len(loaded.Features))
+				loaded.Features = append(loaded.Features, encoded[i])
+				loaded.FeatureNames = append(loaded.FeatureNames, categoricalName + "=" + level)
+				loadedStats.Features = append(loadedStats.Features, ColumnStats{})
+			}
+		} else {
+			loaded.Features = append(loaded.Features, numericColumns[column])
+			loaded.FeatureNames = append(loaded.FeatureNames, numericName)
+			loadedStats.Features = append(loadedStats.Features, stats[column])
+		}
+	}
+	switch opts.Missing {
+	case "", "drop":
+		loaded = DropMissing(loaded)
+	case "mean":
+		loaded = ImputeMean(loaded)
+		//an imputed mean moves neither the mean nor the sum of squared deviations, it only adds to the count
+		loadedStats.X.Count, loadedStats.Y.Count = len(loaded.X), len(loaded.Y)
+		for i := range loadedStats.Features {
+			loadedStats.Features[i].Count = len(loaded.X)
+		}
+	}
+	for _, feature := range oneHot {
+		loadedStats.Features[feature] = columnStats(loaded.Features[feature])
+	}
+	loaded.Stats = loadedStats
+	if len(loaded.X) == 0 {
+		return loaded, fmt.Errorf("%w to train on in %s", ErrEmptyData, filename)
+	}
+	return loaded, nil
+}
+
+// Rows of a data file read before its size is estimated from the bytes they took
+const sizingRows = 1000
+
+// Estimates the rows of a data file from the bytes its first rowsRead rows took, with a little headroom. 0 if it
+// cannot tell, as for compressed files, workbooks and JSON Lines, whose size is not that of their csv rows
+func estimateRows(rows rowReader, filename string, rowsRead int) int {
+	offsetReader, ok := rows.(interface{ InputOffset() int64 }) //a *csv.Reader
+	if !ok || strings.HasSuffix(filename, ".gz") {
+		return 0
+	}
+	info, err := os.Stat(filename)
+	offset := offsetReader.InputOffset()
+	if err != nil || offset <= 0 {
+		return 0
+	}
+	return int(1.05 * float64(info.Size()) / float64(offset) * float64(rowsRead))
+}
 
-		xVector = append(xVector, x)
-		yVector = append(yVector, y)
+// Returns a copy of a column with room for rows values
+func growColumn(column []float64, rows int) []float64 {
+	return append(make([]float64, 0, rows), column...)
+}
+
+// Adds the numeric cells of a row, those that are not NaN for being categorical, to the statistics of their columns:
+// every observed value, or if dropIncomplete only the values of rows without missing ones, so the statistics describe
+// the rows the drop strategy keeps
+func addRowStats(stats []ColumnStats, values []float64, isCategorical map[int]bool, dropIncomplete bool) {
+	for column, value := range values {
+		if dropIncomplete && math.IsNaN(value) && (!isCategorical[column] || column == 0 || column == len(values) - 1) {
+			return
+		}
+	}
+	for column, value := range values {
+		if !math.IsNaN(value) {
+			stats[column].Add(value)
+		}
+	}
+}
+
+// Returns the indexes of the header's columns named by features, or every column but target in file order if there
+// are none, followed by the index of target
+func selectColumns(header []string, target string, features []string, filename string) ([]int, error) {
+	index := make(map[string]int)
+	for i, name := range header {
+		index[strings.TrimSpace(name)] = i
+	}
+	targetColumn, ok := index[target]
+	if !ok {
+		return nil, fmt.Errorf("data: %s has no target column %s", filename, target)
+	}
+	var selected []int
+	if len(features) == 0 {
+		for i := range header {
+			if i != targetColumn {
+				selected = append(selected, i)
+			}
+		}
+	}
+	for _, feature := range features {
+		column, ok := index[feature]
+		if !ok {
+			return nil, fmt.Errorf("data: %s has no feature column %s", filename, feature)
+		}
+		if column == targetColumn {
+			return nil, fmt.Errorf("data: the target column %s cannot also be a feature", target)
+		}
+		selected = append(selected, column)
+	}
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("data: %s has no column besides the target %s", filename, target)
+	}
+	return append(selected, targetColumn), nil
+}
+
+// Returns the cells of a row at the given columns, in their order
+func pickColumns(line []string, columns []int) []string {
+	picked := make([]string, len(columns))
+	for i, column := range columns {
+		picked[i] = line[column]
+	}
+	return picked
+}
+
+// A source of the rows of a data file, each a slice of cells as a csv.Reader reads them
+type rowReader interface {
+	Read() ([]string, error)
+}
+
+// Opens a data file for reading rows by its extension: a sheet of an .xlsx workbook, a .jsonl or .ndjson JSON Lines
+// file, a .libsvm, .svm or .svmlight sparse file, or else a csv file. The returned function closes the file
+func openRows(filename string, opts LoadOptions) (rowReader, func(), error) {
+	switch dataExtension(filename) {
+	case ".xlsx":
+		rows, err := openXLSX(filename, opts.Sheet)
+		if err != nil {
+			return nil, nil, err
+		}
+		return rows, rows.Close, nil
+	case ".jsonl", ".ndjson":
+		return openNDJSON(filename, opts.Target, opts.Features)
+	case ".libsvm", ".svm", ".svmlight":
+		return openLIBSVM(filename, opts.Target != "")
+	}
+	return openCSV(filename)
+}
+
+// Returns the lower cased extension of a data file, the one before .gz if it is gzip compressed
+func dataExtension(filename string) string {
+	filename = strings.ToLower(filename)
+	return filepath.Ext(strings.TrimSuffix(filename, ".gz"))
+}
+
+// Opens a csv file for reading, decompressing it if the filename ends in .gz. The returned function closes the file.
+// A .parquet file is ErrUnreadableFormat rather than parsed as csv, as there is no parquet reader
+func openCSV(filename string) (*csv.Reader, func(), error) {
+	if dataExtension(filename) == ".parquet" {
+		return nil, nil, fmt.Errorf("%w %s: .parquet input is not supported, generate a .csv to search on instead", ErrUnreadableFormat, filename)
+	}
+	file, closeFile, err := openFile(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	return csv.NewReader(file), closeFile, nil
+}
+
+// Opens a file for reading, decompressing it if the filename ends in .gz. The returned function closes the file
+func openFile(filename string) (io.Reader, func(), error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, nil, fmt.Errorf("data: cannot open data file %s: %v", filename, err)
+	}
+	if !strings.HasSuffix(filename, ".gz") {
+		return file, func() { file.Close() }, nil
+	}
+	gzipReader, err := gzip.NewReader(file)
+	if err != nil {
+		file.Close()
+		return nil, nil, fmt.Errorf("data: cannot open gzip compressed data file %s: %v", filename, err)
+	}
+	return gzipReader, func() {
+		gzipReader.Close()
+		file.Close()
+	}, nil
+}
+
+// Parses a csv cell, returning NaN for empty or unparseable cells so they can be handled as missing values
+func parseCell(cell string) float64 {
+	value, err := strconv.ParseFloat(strings.TrimSpace(cell), 64)
+	if err != nil {
+		return math.NaN()
 	}
-	return InputData{xVector, yVector}
+	return value
 }
@@ -0,0 +1,27 @@
+package data
+
+// Member variables represent independent (x) and dependent (y) variables. Features holds any additional feature
+// columns between x and y (one slice per column, each as long as X), named by FeatureNames, which the fit never uses:
+// it fits y on x alone. It is kept apart from the file loaders, which the in-memory core, regression and gridsearch, never calls: the core
+// reads no files or stdin, so it runs where there are none, eg compiled to js/wasm for a browser
+type InputData struct {
+	X []float64
+	Y []float64
+	Features [][]float64
+	FeatureNames []string
+	Stats *DataStats // per column statistics gathered by LoadTrainingData, nil for data built or transformed since
+}
+
+// Creates empty data with room for rows rows of x, y and numFeatures feature columns, so appending that many rows
+// never reallocates. Builders that know or can estimate their size up front should use it rather than growing the
+// slices from nothing, which copies them over and over and briefly holds about twice their memory
+func NewInputData(rows int, numFeatures int) InputData {
+	d := InputData{X: make([]float64, 0, rows), Y: make([]float64, 0, rows)}
+	if numFeatures > 0 {
+		d.Features = make([][]float64, numFeatures)
+		for i := range d.Features {
+			d.Features[i] = make([]float64, 0, rows)
+		}
+	}
+	return d
+}
@@ -0,0 +1,130 @@
+package data
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Rows of a sparse LIBSVM/svmlight file, lines of "label index:value index:value ...", densified into the columns of
+// a csv file: the features by index, from 1 (or 0 if the file uses it) to the highest index in the file, and then the
+// label. Features a line leaves out are 0, as sparse formats mean, not missing. qid fields and # comments are ignored.
+// As with a wide csv, only the first feature becomes x: the search fits y on it alone
+type libsvmRows struct {
+	scanner *bufio.Scanner
+	filename string
+	first, last int //range of feature indexes
+	header bool //the first Read returns a header row naming the columns label and by their index
+	line int
+}
+
+// Opens a LIBSVM file, optionally gzip compressed, after a first pass over it for the range of its feature indexes.
+// With header the first row names the columns, so -target label and -features of indexes pick them. The returned
+// function closes the file. A line that is not LIBSVM is a *RowError
+func openLIBSVM(filename string, header bool) (*libsvmRows, func(), error) {
+	rows := &libsvmRows{filename: filename, first: 1, last: 0, header: header}
+	file, closeFile, err := openFile(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	scanner := libsvmScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		_, features, err := parseLIBSVMLine(scanner.Text())
+		if err != nil {
+			closeFile()
+			return nil, nil, &RowError{filename, line, err}
+		}
+		for index := range features {
+			if index == 0 {
+				rows.first = 0
+			}
+			if index > rows.last {
+				rows.last = index
+			}
+		}
+	}
+	err = scanner.Err()
+	closeFile()
+	if err != nil {
+		return nil, nil, fmt.Errorf("data: cannot read LIBSVM file %s: %v", filename, err)
+	}
+	if rows.last < rows.first {
+		return nil, nil, fmt.Errorf("data: LIBSVM file %s has no features", filename)
+	}
+	if file, closeFile, err = openFile(filename); err != nil {
+		return nil, nil, err
+	}
+	rows.scanner = libsvmScanner(file)
+	return rows, closeFile, nil
+}
+
+func libsvmScanner(file io.Reader) *bufio.Scanner {
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64 * 1024), 64 * 1024 * 1024) //lines of wide files run long
+	return scanner
+}
+
+// Returns the next line of the file as a dense row of its features and then its label, io.EOF after the last one
+func (r *libsvmRows) Read() ([]string, error) {
+	width := r.last - r.first + 1
+	if r.header {
+		r.header = false
+		row := make([]string, 0, width + 1)
+		for index := r.first; index <= r.last; index++ {
+			row = append(row, strconv.Itoa(index))
+		}
+		return append(row, "label"), nil
+	}
+	for r.scanner.Scan() {
+		r.line++
+		label, features, err := parseLIBSVMLine(r.scanner.Text())
+		if err != nil {
+			return nil, &RowError{r.filename, r.line, err}
+		}
+		if label == "" { //blank or comment line
+			continue
+		}
+		row := make([]string, width + 1)
+		for i := range row[:width] {
+			row[i] = "0"
+		}
+		for index, value := range features {
+			row[index - r.first] = value
+		}
+		row[width] = label
+		return row, nil
+	}
+	if err := r.scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
+
+// Splits a line of a LIBSVM file into its label and its values by feature index, a blank label for a blank line
+func parseLIBSVMLine(line string) (string, map[int]string, error) {
+	if comment := strings.IndexByte(line, '#'); comment >= 0 {
+		line = line[:comment]
+	}
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return "", nil, nil
+	}
+	features := make(map[int]string, len(fields) - 1)
+	for _, field := range fields[1:] {
+		colon := strings.IndexByte(field, ':')
+		if colon < 0 {
+			return "", nil, fmt.Errorf("%q is not index:value", field)
+		}
+		if field[:colon] == "qid" {
+			continue
+		}
+		index, err := strconv.Atoi(field[:colon])
+		if err != nil || index < 0 {
+			return "", nil, fmt.Errorf("%q does not have a feature index", field)
+		}
+		features[index] = field[colon + 1:]
+	}
+	return fields[0], features, nil
+}
@@ -0,0 +1,67 @@
+package data
+
+import "math"
+
+// Removes every row where x, y or any feature is missing (NaN). Data without missing values is returned as is rather
+// than copied
+func DropMissing(d InputData) InputData {
+	complete := make([]int, 0, len(d.X))
+	for i := 0; i < len(d.X); i++ {
+		missing := math.IsNaN(d.X[i]) || math.IsNaN(d.Y[i])
+		for _, feature := range d.Features {
+			missing = missing || math.IsNaN(feature[i])
+		}
+		if !missing {
+			complete = append(complete, i)
+		}
+	}
+	if len(complete) == len(d.X) {
+		return d
+	}
+	return SelectRows(d, complete)
+}
+
+// Replaces missing (NaN) values with the mean of the observed values in the same column
+func ImputeMean(d InputData) InputData {
+	output := InputData{X: imputeColumnMean(d.X), Y: imputeColumnMean(d.Y), FeatureNames: d.FeatureNames}
+	for _, feature := range d.Features {
+		output.Features = append(output.Features, imputeColumnMean(feature))
+	}
+	return output
+}
+
+// Counts the missing (NaN) values of x and y
+func CountMissing(d InputData) (int, int) {
+	missingX, missingY := 0, 0
+	for i := 0; i < len(d.X); i++ {
+		if math.IsNaN(d.X[i]) {
+			missingX++
+		}
+		if math.IsNaN(d.Y[i]) {
+			missingY++
+		}
+	}
+	return missingX, missingY
+}
+
+func imputeColumnMean(column []float64) []float64 {
+	sum, count := float64(0), 0
+	for _, value := range column {
+		if !math.IsNaN(value) {
+			sum += value
+			count++
+		}
+	}
+	mean := float64(0)
+	if count > 0 {
+		mean = sum / float64(count)
+	}
+	output := make([]float64, len(column))
+	for i, value := range column {
+		if math.IsNaN(value) {
+			value = mean
+		}
+		output[i] = value
+	}
+	return output
+}
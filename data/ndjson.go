@@ -0,0 +1,116 @@
+package data
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// Rows of a JSON Lines (NDJSON) file, one object per line, read as the cells of a csv file would be: numbers and
+// strings as written, booleans as 1 and 0, and null, missing or nested fields as "". Blank lines are skipped
+type ndjsonRows struct {
+	reader *bufio.Reader
+	filename string
+	fields []string //fields read into each row, taken from the keys of the first object if nil
+	header bool //the first Read returns fields as a header row
+	pending []string //the row of the first object, held back behind the header
+	line int
+}
+
+// Opens a JSON Lines file, optionally gzip compressed: without target its objects are read as x and y fields, with
+// target as a header row and the fields named by features and then target, or all fields of the first object in the
+// order written if features is empty. The returned function closes the file
+func openNDJSON(filename string, target string, features []string) (*ndjsonRows, func(), error) {
+	file, closeFile, err := openFile(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	rows := &ndjsonRows{reader: bufio.NewReader(file), filename: filename, fields: []string{"x", "y"}}
+	if target != "" {
+		rows.header, rows.fields = true, nil
+		if len(features) > 0 {
+			rows.fields = append(append([]string{}, features...), target)
+		}
+	}
+	return rows, closeFile, nil
+}
+
+// Returns the next object of the file as a row of its fields, io.EOF after the last one
+func (r *ndjsonRows) Read() ([]string, error) {
+	if r.pending != nil {
+		row := r.pending
+		r.pending = nil
+		return row, nil
+	}
+	var line []byte
+	for {
+		var err error
+		line, err = r.reader.ReadBytes('\n')
+		r.line++
+		if len(bytes.TrimSpace(line)) > 0 {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	var record map[string]json.RawMessage
+	if err := json.Unmarshal(line, &record); err != nil {
+		return nil, &RowError{r.filename, r.line, fmt.Errorf("not a JSON object: %v", err)}
+	}
+	if r.fields == nil {
+		r.fields = objectKeys(line)
+	}
+	row := make([]string, len(r.fields))
+	for i, field := range r.fields {
+		row[i] = ndjsonCell(record[field])
+	}
+	if r.header {
+		r.header, r.pending = false, row
+		return append([]string{}, r.fields...), nil
+	}
+	return row, nil
+}
+
+// Returns the keys of a JSON object in the order they are written
+func objectKeys(object []byte) []string {
+	decoder := json.NewDecoder(bytes.NewReader(object))
+	decoder.Token() //the opening brace
+	var keys []string
+	for decoder.More() {
+		key, err := decoder.Token()
+		if err != nil {
+			break
+		}
+		keys = append(keys, key.(string))
+		var value json.RawMessage
+		if decoder.Decode(&value) != nil {
+			break
+		}
+	}
+	return keys
+}
+
+// Converts a field of an object to a csv cell
+func ndjsonCell(value json.RawMessage) string {
+	var cell interface{}
+	decoder := json.NewDecoder(bytes.NewReader(value))
+	decoder.UseNumber()
+	if len(value) == 0 || decoder.Decode(&cell) != nil {
+		return ""
+	}
+	switch cell := cell.(type) {
+	case json.Number:
+		return cell.String()
+	case string:
+		return cell
+	case bool:
+		if cell {
+			return "1"
+		}
+		return "0"
+	default: //null, arrays and objects
+		return ""
+	}
+}
@@ -0,0 +1,271 @@
+package data
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"strconv"
+)
+
+// A minimal parquet writer for generated data: every column is an optional DOUBLE, written as one uncompressed PLAIN
+// encoded data page per row group. Missing (empty) cells become nulls. Metadata is serialized with the thrift compact protocol
+// as described in https://github.com/apache/parquet-format
+
+const parquetMagic = "PAR1"
+
+// parquet-format enum values used by the writer
+const (
+	parquetTypeDouble = 5
+	parquetRepetitionOptional = 1
+	parquetEncodingPlain = 0
+	parquetEncodingRLE = 3
+	parquetCodecUncompressed = 0
+	parquetPageTypeData = 0
+)
+
+// Location and size of one column chunk. dataPageOffset is relative to the start of its row group until the merger
+// writes the row group and knows its position in the file
+type parquetColumnChunk struct {
+	dataPageOffset int64
+	size int64
+	numValues int64
+}
+
+type parquetRowGroup struct {
+	columns []parquetColumnChunk
+	numRows int64
+}
+
+// Names the parquet columns like the csv layout: x, then any extra features x1..xk, then y
+func parquetColumnNames(numColumns int) []string {
+	names := []string{"x"}
+	for i := 1; i < numColumns - 1; i++ {
+		names = append(names, "x" + strconv.Itoa(i))
+	}
+	return append(names, "y")
+}
+
+// Encodes rows as the column chunks of one row group
+func encodeParquetRowGroup(rows [][]string) encodedChunk {
+	if len(rows) == 0 {
+		return encodedChunk{}
+	}
+	var buffer bytes.Buffer
+	columns := make([]parquetColumnChunk, 0, len(rows[0]))
+	for column := 0; column < len(rows[0]); column++ {
+		definitionLevels := make([]byte, len(rows))
+		var values bytes.Buffer
+		for i, row := range rows {
+			value := parseCell(row[column])
+			if math.IsNaN(value) {
+				continue //null, definition level 0
+			}
+			definitionLevels[i] = 1
+			binary.Write(&values, binary.LittleEndian, value)
+		}
+		var page bytes.Buffer
+		encodedLevels := encodeRLELevels(definitionLevels)
+		binary.Write(&page, binary.LittleEndian, uint32(len(encodedLevels)))
+		page.Write(encodedLevels)
+		page.Write(values.Bytes())
+
+		header := newThriftWriter()
+		header.writeI32(1, parquetPageTypeData)
+		header.writeI32(2, int32(page.Len()))
+		header.writeI32(3, int32(page.Len()))
+		header.beginStruct(5)
+		header.writeI32(1, int32(len(rows)))
+		header.writeI32(2, parquetEncodingPlain)
+		header.writeI32(3, parquetEncodingRLE)
+		header.writeI32(4, parquetEncodingRLE)
+		header.endStruct()
+		headerBytes := header.finish()
+
+		columns = append(columns, parquetColumnChunk{int64(buffer.Len()), int64(len(headerBytes) + page.Len()), int64(len(rows))})
+		buffer.Write(headerBytes)
+		buffer.Write(page.Bytes())
+	}
+	return encodedChunk{bytes: buffer.Bytes(), numRows: len(rows), columns: columns}
+}
+
+// Encodes definition levels of bit width 1 with the RLE half of parquet's RLE/bit-packing hybrid encoding
+func encodeRLELevels(levels []byte) []byte {
+	var buffer bytes.Buffer
+	for start := 0; start < len(levels); {
+		end := start
+		for end < len(levels) && levels[end] == levels[start] {
+			end++
+		}
+		writeUvarint(&buffer, uint64(end - start) << 1)
+		buffer.WriteByte(levels[start])
+		start = end
+	}
+	return buffer.Bytes()
+}
+
+// Records the row group of a chunk about to be written at the current file offset
+func (output *generatedFile) addRowGroup(chunk encodedChunk) {
+	if output.columnNames == nil {
+		output.columnNames = parquetColumnNames(len(chunk.columns))
+	}
+	columns := make([]parquetColumnChunk, len(chunk.columns))
+	for i, column := range chunk.columns {
+		column.dataPageOffset += output.offset
+		columns[i] = column
+	}
+	output.rowGroups = append(output.rowGroups, parquetRowGroup{columns, int64(chunk.numRows)})
+}
+
+// Serializes the FileMetaData footer followed by its length and the closing magic bytes
+func (output *generatedFile) parquetFooter() []byte {
+	numRows := int64(0)
+	for _, rowGroup := range output.rowGroups {
+		numRows += rowGroup.numRows
+	}
+	metadata := newThriftWriter()
+	metadata.writeI32(1, 1) //version
+	metadata.beginList(2, thriftStruct, len(output.columnNames) + 1)
+	metadata.beginListStruct() //root of the schema tree
+	metadata.writeString(4, "schema")
+	metadata.writeI32(5, int32(len(output.columnNames)))
+	metadata.endStruct()
+	for _, name := range output.columnNames {
+		metadata.beginListStruct()
+		metadata.writeI32(1, parquetTypeDouble)
+		metadata.writeI32(3, parquetRepetitionOptional)
+		metadata.writeString(4, name)
+		metadata.endStruct()
+	}
+	metadata.writeI64(3, numRows)
+	metadata.beginList(4, thriftStruct, len(output.rowGroups))
+	for _, rowGroup := range output.rowGroups {
+		metadata.beginListStruct()
+		metadata.beginList(1, thriftStruct, len(rowGroup.columns))
+		totalSize := int64(0)
+		for i, column := range rowGroup.columns {
+			totalSize += column.size
+			metadata.beginListStruct()
+			metadata.writeI64(2, column.dataPageOffset)
+			metadata.beginStruct(3)
+			metadata.writeI32(1, parquetTypeDouble)
+			metadata.beginList(2, thriftI32, 2)
+			metadata.listI32(parquetEncodingPlain)
+			metadata.listI32(parquetEncodingRLE)
+			metadata.beginList(3, thriftBinary, 1)
+			metadata.listString(output.columnNames[i])
+			metadata.writeI32(4, parquetCodecUncompressed)
+			metadata.writeI64(5, column.numValues)
+			metadata.writeI64(6, column.size)
+			metadata.writeI64(7, column.size)
+			metadata.writeI64(9, column.dataPageOffset)
+			metadata.endStruct()
+			metadata.endStruct()
+		}
+		metadata.writeI64(2, totalSize)
+		metadata.writeI64(3, rowGroup.numRows)
+		metadata.endStruct()
+	}
+	metadata.writeString(6, "proj3 data generator")
+	metadataBytes := metadata.finish()
+
+	var footer bytes.Buffer
+	footer.Write(metadataBytes)
+	binary.Write(&footer, binary.LittleEndian, uint32(len(metadataBytes)))
+	footer.WriteString(parquetMagic)
+	return footer.Bytes()
+}
+
+// Thrift compact protocol type ids
+const (
+	thriftI32 = 5
+	thriftI64 = 6
+	thriftBinary = 8
+	thriftList = 9
+	thriftStruct = 12
+)
+
+// Writes a thrift struct with the compact protocol. Field ids are delta encoded against the previous field of the
+// innermost open struct, so a stack of previous ids is kept
+type thriftWriter struct {
+	buffer bytes.Buffer
+	lastField []int16
+}
+
+func newThriftWriter() *thriftWriter {
+	return &thriftWriter{lastField: []int16{0}}
+}
+
+func (w *thriftWriter) fieldHeader(id int16, fieldType byte) {
+	last := &w.lastField[len(w.lastField) - 1]
+	if delta := id - *last; delta > 0 && delta <= 15 {
+		w.buffer.WriteByte(byte(delta) << 4 | fieldType)
+	} else {
+		w.buffer.WriteByte(fieldType)
+		writeZigzag(&w.buffer, int64(id))
+	}
+	*last = id
+}
+
+func (w *thriftWriter) writeI32(id int16, value int32) {
+	w.fieldHeader(id, thriftI32)
+	writeZigzag(&w.buffer, int64(value))
+}
+
+func (w *thriftWriter) writeI64(id int16, value int64) {
+	w.fieldHeader(id, thriftI64)
+	writeZigzag(&w.buffer, value)
+}
+
+func (w *thriftWriter) writeString(id int16, value string) {
+	w.fieldHeader(id, thriftBinary)
+	w.listString(value)
+}
+
+func (w *thriftWriter) beginStruct(id int16) {
+	w.fieldHeader(id, thriftStruct)
+	w.lastField = append(w.lastField, 0)
+}
+
+// Starts a struct element of a list, which has no field header of its own
+func (w *thriftWriter) beginListStruct() {
+	w.lastField = append(w.lastField, 0)
+}
+
+func (w *thriftWriter) endStruct() {
+	w.buffer.WriteByte(0)
+	w.lastField = w.lastField[:len(w.lastField) - 1]
+}
+
+func (w *thriftWriter) beginList(id int16, elementType byte, size int) {
+	w.fieldHeader(id, thriftList)
+	if size < 15 {
+		w.buffer.WriteByte(byte(size) << 4 | elementType)
+	} else {
+		w.buffer.WriteByte(0xf0 | elementType)
+		writeUvarint(&w.buffer, uint64(size))
+	}
+}
+
+func (w *thriftWriter) listI32(value int32) {
+	writeZigzag(&w.buffer, int64(value))
+}
+
+func (w *thriftWriter) listString(value string) {
+	writeUvarint(&w.buffer, uint64(len(value)))
+	w.buffer.WriteString(value)
+}
+
+// Closes the top level struct and returns its bytes
+func (w *thriftWriter) finish() []byte {
+	w.buffer.WriteByte(0)
+	return w.buffer.Bytes()
+}
+
+func writeUvarint(buffer *bytes.Buffer, value uint64) {
+	var scratch [binary.MaxVarintLen64]byte
+	buffer.Write(scratch[:binary.PutUvarint(scratch[:], value)])
+}
+
+func writeZigzag(buffer *bytes.Buffer, value int64) {
+	writeUvarint(buffer, uint64((value << 1) ^ (value >> 63)))
+}
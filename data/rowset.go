@@ -0,0 +1,80 @@
+package data
+
+import (
+	"math"
+	"math/rand"
+)
+
+// Rows of an InputData picked by index, in order. Shuffles, splits and mini-batches are orderings of indexes into the
+// data's columns rather than copies of them, so holdout splits, folds and batches all share one set of columns until
+// Select copies out the rows that are actually needed on their own
+type RowSet struct {
+	Data InputData
+	Indices []int
+}
+
+// Returns every row of d in order
+func (d InputData) All() RowSet {
+	indices := make([]int, len(d.X))
+	for i := range indices {
+		indices[i] = i
+	}
+	return RowSet{d, indices}
+}
+
+// Returns every row of d in an order shuffled by seed; the same seed always gives the same order
+func (d InputData) Shuffle(seed int64) RowSet {
+	return RowSet{d, rand.New(rand.NewSource(seed)).Perm(len(d.X))}
+}
+
+// Splits the rows of d in order into the first frac of them and the rest, eg a holdout of the latest rows of data in
+// time order. Shuffle first for a random split
+func (d InputData) Split(frac float64) (RowSet, RowSet) {
+	return d.All().Split(frac)
+}
+
+// Returns the number of rows
+func (r RowSet) Len() int {
+	return len(r.Indices)
+}
+
+// Returns the rows in an order shuffled by seed, leaving r as is
+func (r RowSet) Shuffle(seed int64) RowSet {
+	permutation := rand.New(rand.NewSource(seed)).Perm(len(r.Indices))
+	for i, j := range permutation {
+		permutation[i] = r.Indices[j]
+	}
+	return RowSet{r.Data, permutation}
+}
+
+// Shuffles the rows in place with rng, eg to reorder a run's mini-batches every epoch from one random source
+func (r RowSet) Permute(rng *rand.Rand) {
+	rng.Shuffle(len(r.Indices), func(i, j int) { r.Indices[i], r.Indices[j] = r.Indices[j], r.Indices[i] })
+}
+
+// Splits the rows in order into the first frac of them, rounded, and the rest. Both share r's indexes
+func (r RowSet) Split(frac float64) (RowSet, RowSet) {
+	n := int(math.Round(math.Max(0, math.Min(1, frac)) * float64(len(r.Indices))))
+	return RowSet{r.Data, r.Indices[:n]}, RowSet{r.Data, r.Indices[n:]}
+}
+
+// Returns the rows in consecutive batches of size, the last holding any left over. The batches share r's indexes
+func (r RowSet) Batches(size int) [][]int {
+	if size < 1 {
+		size = 1
+	}
+	batches := make([][]int, 0, (len(r.Indices) + size - 1) / size)
+	for start := 0; start < len(r.Indices); start += size {
+		end := start + size
+		if end > len(r.Indices) {
+			end = len(r.Indices)
+		}
+		batches = append(batches, r.Indices[start:end])
+	}
+	return batches
+}
+
+// Copies the rows out into data of their own
+func (r RowSet) Select() InputData {
+	return SelectRows(r.Data, r.Indices)
+}
@@ -0,0 +1,188 @@
+package data
+
+import (
+	"encoding/csv"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// Shuffles the rows of d with the given seed and splits them into consecutive parts, one per fraction in fracs.
+// Fractions must be positive and sum to at most 1, else the error is ErrBadSplit; when they sum to 1 the last part
+// takes any rows left over by rounding
+func Split(d InputData, fracs []float64, seed int64) ([]InputData, error) {
+	counts, err := splitCounts(len(d.X), fracs)
+	if err != nil {
+		return nil, err
+	}
+	rows := d.Shuffle(seed)
+	parts := make([]InputData, 0, len(fracs))
+	start := 0
+	for _, count := range counts {
+		parts = append(parts, RowSet{d, rows.Indices[start:start + count]}.Select())
+		start += count
+	}
+	return parts, nil
+}
+
+// Like Split, but stratified by y: rows are grouped into bins quantiles of y and every bin is split by fracs on its
+// own, so each part covers the full response range even when it is small. Rows with a missing y form their own bin
+func SplitStratified(d InputData, fracs []float64, bins int, seed int64) ([]InputData, error) {
+	if _, err := splitCounts(0, fracs); err != nil {
+		return nil, err
+	}
+	rng := rand.New(rand.NewSource(seed))
+	partIndices := make([][]int, len(fracs))
+	for _, bin := range quantileBins(d.Y, bins) {
+		rng.Shuffle(len(bin), func(i, j int) { bin[i], bin[j] = bin[j], bin[i] })
+		counts, _ := splitCounts(len(bin), fracs)
+		start := 0
+		for part, count := range counts {
+			partIndices[part] = append(partIndices[part], bin[start:start + count]...)
+			start += count
+		}
+	}
+	parts := make([]InputData, 0, len(fracs))
+	for _, indices := range partIndices {
+		rng.Shuffle(len(indices), func(i, j int) { indices[i], indices[j] = indices[j], indices[i] }) //undo the ordering by bin
+		parts = append(parts, SelectRows(d, indices))
+	}
+	return parts, nil
+}
+
+// Returns how many of n rows go into each part of a split by fracs, or ErrBadSplit if the fractions are not positive
+// or sum to more than 1
+func splitCounts(n int, fracs []float64) ([]int, error) {
+	total := float64(0)
+	for _, frac := range fracs {
+		if !(frac > 0) {
+			return nil, fmt.Errorf("%w: %v is not positive", ErrBadSplit, frac)
+		}
+		total += frac
+	}
+	if total > 1 + 1e-9 {
+		return nil, fmt.Errorf("%w: they sum to %v, more than 1", ErrBadSplit, total)
+	}
+
+	counts := make([]int, 0, len(fracs))
+	start := 0
+	for i, frac := range fracs {
+		end := start + int(math.Round(frac * float64(n)))
+		if end > n || (i == len(fracs) - 1 && math.Abs(total - 1) < 1e-9) {
+			end = n
+		}
+		counts = append(counts, end - start)
+		start = end
+	}
+	return counts, nil
+}
+
+// Groups row indexes into bins of (nearly) equal size by quantile of values, plus a last bin of NaN values if any
+func quantileBins(values []float64, bins int) [][]int {
+	if bins < 1 {
+		bins = 1
+	}
+	sorted := make([]int, 0, len(values))
+	missing := make([]int, 0)
+	for i, value := range values {
+		if math.IsNaN(value) {
+			missing = append(missing, i)
+		} else {
+			sorted = append(sorted, i)
+		}
+	}
+	sort.SliceStable(sorted, func(i, j int) bool { return values[sorted[i]] < values[sorted[j]] })
+
+	output := make([][]int, 0, bins + 1)
+	for b := 0; b < bins; b++ {
+		start, end := b * len(sorted) / bins, (b + 1) * len(sorted) / bins
+		if end > start {
+			output = append(output, append([]int(nil), sorted[start:end]...))
+		}
+	}
+	if len(missing) > 0 {
+		output = append(output, missing)
+	}
+	return output
+}
+
+// Returns n rows of d drawn at random without replacement. A seed of 0 draws a random seed
+func Sample(d InputData, n int, seed int64) InputData {
+	if n >= len(d.X) {
+		return d
+	}
+	if seed == 0 {
+		seed = rand.Int63()
+	}
+	return RowSet{d, d.Shuffle(seed).Indices[:n]}.Select()
+}
+
+// Like Sample, but stratified by bins quantiles of y so a small sample still represents the full response range.
+// The sample size can differ from n by up to one row per bin due to rounding within bins. An n below 1 is ErrBadSplit
+func SampleStratified(d InputData, n int, bins int, seed int64) (InputData, error) {
+	if n >= len(d.X) {
+		return d, nil
+	}
+	if seed == 0 {
+		seed = rand.Int63()
+	}
+	parts, err := SplitStratified(d, []float64{float64(n) / float64(len(d.X))}, bins, seed)
+	if err != nil {
+		return d, err
+	}
+	return parts[0], nil
+}
+
+// Draws a bootstrap resample of the data: as many rows as the data, drawn uniformly with replacement. A seed of 0
+// picks a random seed
+func Bootstrap(d InputData, seed int64) InputData {
+	if seed == 0 {
+		seed = rand.Int63()
+	}
+	rng := rand.New(rand.NewSource(seed))
+	indices := make([]int, len(d.X))
+	for i := range indices {
+		indices[i] = rng.Intn(len(d.X))
+	}
+	return SelectRows(d, indices)
+}
+
+// Writes training data to a csv file in the same x,features...,y layout LoadTrainingData reads. One-hot encoded
+// features are written as their 0/1 columns. Missing (NaN) values are written as empty cells. On an error the file is
+// left as it was
+func WriteTrainingData(d InputData, outputFilePath string) error {
+	file, err := CreateAtomic(outputFilePath)
+	if err != nil {
+		return fmt.Errorf("data: could not create %s: %v", outputFilePath, err)
+	}
+	writer := csv.NewWriter(file)
+
+	for i := 0; i < len(d.X); i++ {
+		row := []string{formatCell(d.X[i])}
+		for _, feature := range d.Features {
+			row = append(row, formatCell(feature[i]))
+		}
+		err := writer.Write(append(row, formatCell(d.Y[i])))
+		if err != nil {
+			file.Abort()
+			return fmt.Errorf("data: trouble writing to %s: %v", outputFilePath, err)
+		}
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		file.Abort()
+		return fmt.Errorf("data: trouble writing to %s: %v", outputFilePath, err)
+	}
+	if err := file.Commit(); err != nil {
+		return fmt.Errorf("data: trouble writing to %s: %v", outputFilePath, err)
+	}
+	return nil
+}
+
+func formatCell(value float64) string {
+	if math.IsNaN(value) {
+		return ""
+	}
+	return fmt.Sprintf("%f", value)
+}
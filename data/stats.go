@@ -0,0 +1,72 @@
+package data
+
+import "math"
+
+// Count, min, max, mean and variance of a column, accumulated one value at a time with Welford's algorithm, so they
+// take a single pass and the variance stays accurate for values large relative to their spread. The zero ColumnStats
+// has seen no values
+type ColumnStats struct {
+	Count int
+	Min float64
+	Max float64
+	Mean float64
+	m2 float64 //sum of squared deviations from the mean
+}
+
+// Statistics of every column of loaded data, laid out as InputData is
+type DataStats struct {
+	X ColumnStats
+	Y ColumnStats
+	Features []ColumnStats
+}
+
+// Adds a value to the statistics
+func (s *ColumnStats) Add(value float64) {
+	s.Count++
+	if s.Count == 1 || value < s.Min {
+		s.Min = value
+	}
+	if s.Count == 1 || value > s.Max {
+		s.Max = value
+	}
+	delta := value - s.Mean
+	s.Mean += delta / float64(s.Count)
+	s.m2 += delta * (value - s.Mean)
+}
+
+// Returns the sample variance, NaN for fewer than two values
+func (s ColumnStats) Variance() float64 {
+	if s.Count < 2 {
+		return math.NaN()
+	}
+	return s.m2 / float64(s.Count - 1)
+}
+
+// Returns the sample standard deviation, NaN for fewer than two values
+func (s ColumnStats) StdDev() float64 {
+	return math.Sqrt(s.Variance())
+}
+
+// Accumulates the statistics of a whole column
+func columnStats(column []float64) ColumnStats {
+	var s ColumnStats
+	for _, value := range column {
+		s.Add(value)
+	}
+	return s
+}
+
+// Returns the statistics gathered while loading d if they still describe it, ie every column holds exactly the values
+// they counted, else nil. Data sampled while loading, or with missing values kept, has statistics of other rows
+func (d InputData) CurrentStats() *DataStats {
+	s := d.Stats
+	if s == nil || s.X.Count != len(d.X) || s.Y.Count != len(d.Y) || len(s.Features) != len(d.Features) {
+		return nil
+	}
+	for i, feature := range d.Features {
+		if s.Features[i].Count != len(feature) {
+			return nil
+		}
+	}
+	return s
+}
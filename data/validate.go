@@ -0,0 +1,114 @@
+package data
+
+import (
+	"hash/fnv"
+	"io"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Findings of ValidateTrainingData
+type ValidationReport struct {
+	Rows int
+	MissingCells int
+	UnparseableCells ValidationIssue
+	NaNCells ValidationIssue
+	InfCells ValidationIssue
+	MismatchedRows ValidationIssue
+	DuplicateRows ValidationIssue
+	ConstantX bool
+}
+
+// Occurrences of one kind of problem. Lines holds the 1-based csv record numbers of the first maxReportedLines occurrences
+type ValidationIssue struct {
+	Count int
+	Lines []int
+}
+
+const maxReportedLines = 10
+
+func (issue *ValidationIssue) record(lineNumber int) {
+	issue.Count++
+	if len(issue.Lines) < maxReportedLines {
+		issue.Lines = append(issue.Lines, lineNumber)
+	}
+}
+
+// Returns whether the file can be used for a search. Missing cells alone do not fail validation, since the loader
+// handles them with its missing value strategy
+func (report ValidationReport) Passed() bool {
+	return report.Rows > 0 && report.UnparseableCells.Count == 0 && report.NaNCells.Count == 0 && report.InfCells.Count == 0 &&
+		report.MismatchedRows.Count == 0 && report.DuplicateRows.Count == 0 && !report.ConstantX
+}
+
+// Scans a training data csv file for problems that would break or silently skew a search: NaN/Inf values, cells that
+// are not numbers, rows with a different number of columns than the first, exact duplicate rows, and a constant x
+// column (which makes beta unidentifiable). Columns listed in categorical are not required to be numeric
+func ValidateTrainingData(filename string, categorical []int) ValidationReport {
+	csvReader, closeFile, err := openCSV(filename)
+	if err != nil {
+		log.Fatal("Error: ", err)
+	}
+	defer closeFile()
+	csvReader.FieldsPerRecord = -1 //row lengths are checked here instead of failing the read
+
+	isCategorical := make(map[int]bool)
+	for _, column := range categorical {
+		isCategorical[column] = true
+	}
+	var report ValidationReport
+	seenRows := make(map[uint64]bool) //hashes of rows, so memory stays small for large files
+	numColumns := 0
+	minX, maxX := math.Inf(1), math.Inf(-1)
+	for {
+		line, err := csvReader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			log.Fatal("Error: issue with reading line from csv file", line)
+		}
+		report.Rows++
+		lineNumber := report.Rows
+
+		if numColumns == 0 {
+			numColumns = len(line)
+		} else if len(line) != numColumns {
+			report.MismatchedRows.record(lineNumber)
+		}
+
+		rowHash := fnv.New64a()
+		rowHash.Write([]byte(strings.Join(line, "\x00")))
+		if seenRows[rowHash.Sum64()] {
+			report.DuplicateRows.record(lineNumber)
+		}
+		seenRows[rowHash.Sum64()] = true
+
+		for column, cell := range line {
+			cell = strings.TrimSpace(cell)
+			if cell == "" {
+				report.MissingCells++
+				continue
+			}
+			if isCategorical[column] {
+				continue
+			}
+			value, err := strconv.ParseFloat(cell, 64)
+			switch {
+			case err != nil && !strings.Contains(err.Error(), "value out of range"):
+				report.UnparseableCells.record(lineNumber)
+			case math.IsNaN(value):
+				report.NaNCells.record(lineNumber)
+			case math.IsInf(value, 0):
+				report.InfCells.record(lineNumber)
+			case column == 0:
+				minX = math.Min(minX, value)
+				maxX = math.Max(maxX, value)
+			}
+		}
+	}
+	report.ConstantX = minX == maxX
+	return report
+}
@@ -0,0 +1,255 @@
+package data
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Rows of one sheet of an Excel workbook (.xlsx), read as the cells of a csv file would be: numbers as written in
+// the sheet, dates as their serial numbers, booleans as 0 and 1, and missing or error cells as "". Rows are padded
+// to the width of the sheet, as Excel leaves out trailing empty cells
+type xlsxRows struct {
+	archive *zip.ReadCloser
+	sheet io.ReadCloser
+	decoder *xml.Decoder
+	sharedStrings []string
+	width int
+}
+
+// Opens the named sheet of a workbook, or its first sheet if sheet is ""
+func openXLSX(filename string, sheet string) (*xlsxRows, error) {
+	archive, err := zip.OpenReader(filename)
+	if err != nil {
+		return nil, fmt.Errorf("data: cannot open workbook %s: %v", filename, err)
+	}
+	rows := &xlsxRows{archive: archive}
+	if err := rows.openSheet(sheet, filename); err != nil {
+		archive.Close()
+		return nil, err
+	}
+	rows.decoder = xml.NewDecoder(rows.sheet)
+	return rows, nil
+}
+
+// Finds the sheet in the archive and opens it, with the shared strings its cells index into
+func (r *xlsxRows) openSheet(sheet string, filename string) error {
+	files := make(map[string]*zip.File)
+	for _, file := range r.archive.File {
+		files[file.Name] = file
+	}
+	sheetPath, err := xlsxSheetPath(files, sheet, filename)
+	if err != nil {
+		return err
+	}
+	if r.sharedStrings, err = xlsxSharedStrings(files["xl/sharedStrings.xml"], filename); err != nil {
+		return err
+	}
+	if files[sheetPath] == nil {
+		return fmt.Errorf("data: workbook %s has no part %s", filename, sheetPath)
+	}
+	if r.sheet, err = files[sheetPath].Open(); err != nil {
+		return fmt.Errorf("data: cannot read sheet of workbook %s: %v", filename, err)
+	}
+	return nil
+}
+
+// Returns the path in the archive of the named sheet, or of the first one, following the workbook's relationships
+func xlsxSheetPath(files map[string]*zip.File, sheet string, filename string) (string, error) {
+	var workbook struct {
+		Sheets []struct {
+			Name string `xml:"name,attr"`
+			ID string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	var relationships struct {
+		Relationships []struct {
+			ID string `xml:"Id,attr"`
+			Target string `xml:"Target,attr"`
+		} `xml:"Relationship"`
+	}
+	if err := decodeXLSXPart(files["xl/workbook.xml"], &workbook, filename); err != nil {
+		return "", err
+	}
+	if err := decodeXLSXPart(files["xl/_rels/workbook.xml.rels"], &relationships, filename); err != nil {
+		return "", err
+	}
+	if len(workbook.Sheets) == 0 {
+		return "", fmt.Errorf("data: workbook %s has no sheets", filename)
+	}
+	id := workbook.Sheets[0].ID
+	if sheet != "" {
+		id = ""
+		names := make([]string, len(workbook.Sheets))
+		for i, s := range workbook.Sheets {
+			names[i] = s.Name
+			if s.Name == sheet {
+				id = s.ID
+			}
+		}
+		if id == "" {
+			return "", fmt.Errorf("data: workbook %s has no sheet %s, only %s", filename, sheet, strings.Join(names, ", "))
+		}
+	}
+	for _, relationship := range relationships.Relationships {
+		if relationship.ID == id {
+			if strings.HasPrefix(relationship.Target, "/") { //absolute in the package rather than relative to xl/
+				return strings.TrimPrefix(relationship.Target, "/"), nil
+			}
+			return path.Join("xl", relationship.Target), nil
+		}
+	}
+	return "", fmt.Errorf("data: workbook %s does not say where sheet %s is", filename, id)
+}
+
+// Reads the workbook's table of shared strings, which string cells index into. A workbook without strings has none
+func xlsxSharedStrings(file *zip.File, filename string) ([]string, error) {
+	if file == nil {
+		return nil, nil
+	}
+	var table struct {
+		Items []xlsxText `xml:"si"`
+	}
+	if err := decodeXLSXPart(file, &table, filename); err != nil {
+		return nil, err
+	}
+	strings := make([]string, len(table.Items))
+	for i, item := range table.Items {
+		strings[i] = item.String()
+	}
+	return strings, nil
+}
+
+// Text of a shared string or an inline string: plain, or runs of rich text
+type xlsxText struct {
+	Text string `xml:"t"`
+	Runs []struct {
+		Text string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (t xlsxText) String() string {
+	text := t.Text
+	for _, run := range t.Runs {
+		text += run.Text
+	}
+	return text
+}
+
+func decodeXLSXPart(file *zip.File, v interface{}, filename string) error {
+	if file == nil {
+		return fmt.Errorf("data: %s is not an xlsx workbook", filename)
+	}
+	part, err := file.Open()
+	if err != nil {
+		return fmt.Errorf("data: cannot read workbook %s: %v", filename, err)
+	}
+	defer part.Close()
+	if err := xml.NewDecoder(part).Decode(v); err != nil {
+		return fmt.Errorf("data: cannot read workbook %s: %v", filename, err)
+	}
+	return nil
+}
+
+// A cell of a sheet
+type xlsxCell struct {
+	Ref string `xml:"r,attr"`
+	Type string `xml:"t,attr"`
+	Value string `xml:"v"`
+	Inline xlsxText `xml:"is"`
+}
+
+// Returns the next row of the sheet with any cells, io.EOF after the last one
+func (r *xlsxRows) Read() ([]string, error) {
+	for {
+		token, err := r.decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		element, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch element.Name.Local {
+		case "dimension": //eg A1:E501, whose last column is the width of the sheet
+			for _, attr := range element.Attr {
+				if attr.Name.Local == "ref" {
+					if bounds := strings.Split(attr.Value, ":"); len(bounds) == 2 {
+						r.width = xlsxColumn(bounds[1]) + 1
+					}
+				}
+			}
+		case "row":
+			var row struct {
+				Cells []xlsxCell `xml:"c"`
+			}
+			if err := r.decoder.DecodeElement(&row, &element); err != nil {
+				return nil, err
+			}
+			if len(row.Cells) == 0 {
+				continue
+			}
+			return r.cells(row.Cells), nil
+		}
+	}
+}
+
+// Lays out the cells of a row by their column, filling the columns Excel left out with ""
+func (r *xlsxRows) cells(cells []xlsxCell) []string {
+	line := make([]string, 0, r.width)
+	for i, cell := range cells {
+		column := i
+		if cell.Ref != "" {
+			column = xlsxColumn(cell.Ref)
+		}
+		for len(line) < column {
+			line = append(line, "")
+		}
+		line = append(line, r.value(cell))
+	}
+	if r.width == 0 { //a sheet without a dimension is as wide as its first row
+		r.width = len(line)
+	}
+	for len(line) < r.width {
+		line = append(line, "")
+	}
+	return line
+}
+
+func (r *xlsxRows) value(cell xlsxCell) string {
+	switch cell.Type {
+	case "s":
+		index, err := strconv.Atoi(cell.Value)
+		if err != nil || index < 0 || index >= len(r.sharedStrings) {
+			return ""
+		}
+		return r.sharedStrings[index]
+	case "inlineStr":
+		return cell.Inline.String()
+	case "e": //#DIV/0! and the like
+		return ""
+	default: //numbers, booleans as 0 or 1, and strings that formulas return
+		return cell.Value
+	}
+}
+
+// Returns the 0-based column index of a cell reference such as B2 or AA10
+func xlsxColumn(ref string) int {
+	column := 0
+	for _, c := range ref {
+		if c < 'A' || c > 'Z' {
+			break
+		}
+		column = column * 26 + int(c - 'A' + 1)
+	}
+	return column - 1
+}
+
+func (r *xlsxRows) Close() {
+	r.sheet.Close()
+	r.archive.Close()
+}
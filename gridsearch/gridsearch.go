@@ -0,0 +1,250 @@
+// Package gridsearch searches a grid of hyperparameters for the linear model of y on x that gradient descent and its
+// variants calibrate, on data held in memory. It is the engine of calibrate without the tasks, files and flags around
+// it: calibrate expands its tasks into permutations and trains them here, and programs that embed the search use it
+// directly, eg through the c-shared library built from cshared or the WebAssembly module built from wasm. It reads no
+// files or stdin, so it runs wherever Go compiles to
+package gridsearch
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"proj3/data"
+	"proj3/regression"
+	"sync"
+	"time"
+)
+
+// A grid of hyperparameters, named as in calibrate's JSON tasks but with numbers rather than strings. Optimizer is
+// any of gd (the default), nag, linesearch, cd, cg, lbfgs and ransac; Alpha is needed by gd and nag and Threshold by
+// ransac. Loss is squared (the default), epsilon, poisson, gamma or the name of a loss registered with
+// regression.RegisterLoss, which gd and nag train on. Schedule, Warmup and MiniBatchSize also only apply to gd and nag,
+// and Target transforms y before any optimizer trains on it. Seed seeds mini-batch shuffles and ransac, a random seed
+// if 0. A permutation of a grid is a Grid with one value in every dimension it searches and none in the others.
+// Scale is the scaling of x before training, minmax (the default) or standard, and Metric the objective the winner
+// minimizes, mse (the default) or the name of a metric registered with regression.RegisterMetric; both are settings
+// of Search, not dimensions
+type Grid struct {
+	Alpha []float64 `json:"alpha"`
+	NumEpochs []float64 `json:"numEpochs"`
+	Lambda []float64 `json:"lambda"`
+	MiniBatchSize []float64 `json:"miniBatchSize"`
+	Optimizer []string `json:"optimizer"`
+	Momentum []float64 `json:"momentum"`
+	History []float64 `json:"history"`
+	Warmup []float64 `json:"warmup"`
+	Schedule []string `json:"schedule"`
+	MinAlpha []float64 `json:"minAlpha"`
+	Cycle []float64 `json:"cycle"`
+	Seed int64 `json:"seed"`
+	Loss []string `json:"loss"`
+	Epsilon []float64 `json:"epsilon"`
+	Link []string `json:"link"`
+	Threshold []float64 `json:"threshold"`
+	Tolerance []float64 `json:"tolerance"`
+	Factor []float64 `json:"factor"`
+	Patience []float64 `json:"patience"`
+	Target []string `json:"target"`
+	BoxCox []float64 `json:"boxcox"`
+	NoIntercept bool `json:"noIntercept"` // fix mu at 0, for models through the origin
+	NonNegative string `json:"nonNegative"` // parameters projected onto [0, inf) after every update: "beta", "all" (beta and mu), or "" for none
+	Scale string `json:"scale"`
+	Metric string `json:"metric"`
+}
+
+// One permutation of a grid as a result reports it. Dimensions the permutation does not search are 0 or ""
+type Config struct {
+	Alpha float64 `json:"alpha"`
+	NumEpochs int `json:"numEpochs"`
+	Lambda float64 `json:"lambda"`
+	Optimizer string `json:"optimizer"`
+	Momentum float64 `json:"momentum"`
+	History int `json:"history"`
+	Loss string `json:"loss,omitempty"` // "" for squared
+	MiniBatchSize int `json:"miniBatchSize,omitempty"`
+	Warmup float64 `json:"warmup,omitempty"`
+	Schedule string `json:"schedule,omitempty"`
+	MinAlpha float64 `json:"minAlpha,omitempty"`
+	Cycle float64 `json:"cycle,omitempty"`
+	Factor float64 `json:"factor,omitempty"`
+	Patience int `json:"patience,omitempty"`
+	Epsilon float64 `json:"epsilon,omitempty"`
+	Link string `json:"link,omitempty"`
+	Threshold float64 `json:"threshold,omitempty"`
+	Tolerance float64 `json:"tolerance,omitempty"`
+	Target string `json:"target,omitempty"`
+	BoxCox float64 `json:"boxcox,omitempty"`
+}
+
+// The winner of a search: its permutation, the parameters it calibrated on the original scale of x, and their MSE
+// and score by the grid's metric on the data. Evaluated is the number of permutations searched. Permutation and Stats,
+// what training observed, are left out of the JSON, which Config describes
+type Result struct {
+	Config Config `json:"config"`
+	Beta float64 `json:"beta"`
+	Mu float64 `json:"mu"`
+	MSE float64 `json:"mse"`
+	Metric string `json:"metric"`
+	Score float64 `json:"score"`
+	Evaluated int `json:"evaluated"`
+	Permutation Grid `json:"-"`
+	Stats Stats `json:"-"`
+}
+
+// Errors of a search that cannot run
+var (
+	ErrEmptyGrid = errors.New("gridsearch: the grid has no permutations")
+	ErrNoData = errors.New("gridsearch: no rows to search on")
+	ErrDiverged = errors.New("gridsearch: every permutation diverged")
+)
+
+// Returns every permutation of the grid as results report them, or an error if the grid is invalid or has none
+func (g Grid) Configs() ([]Config, error) {
+	permutations, err := g.Permutations()
+	if err != nil {
+		return nil, err
+	}
+	if len(permutations) == 0 {
+		return nil, ErrEmptyGrid
+	}
+	configs := make([]Config, len(permutations))
+	for i, permutation := range permutations {
+		configs[i] = permutation.config()
+	}
+	return configs, nil
+}
+
+// Describes a permutation as a Config
+func (g Grid) config() Config {
+	first := func(values []float64) float64 {
+		if values == nil {
+			return 0
+		}
+		return values[0]
+	}
+	choice := func(values []string) string {
+		if values == nil {
+			return ""
+		}
+		return values[0]
+	}
+	c := Config{Alpha: first(g.Alpha), NumEpochs: int(first(g.NumEpochs)), Lambda: first(g.Lambda), Optimizer: choice(g.Optimizer),
+		Momentum: first(g.Momentum), History: int(first(g.History)), MiniBatchSize: int(first(g.MiniBatchSize)), Warmup: first(g.Warmup),
+		Schedule: choice(g.Schedule), MinAlpha: first(g.MinAlpha), Cycle: first(g.Cycle), Factor: first(g.Factor),
+		Patience: int(first(g.Patience)), Epsilon: first(g.Epsilon), Link: choice(g.Link), Threshold: first(g.Threshold),
+		Tolerance: first(g.Tolerance), Target: choice(g.Target), BoxCox: first(g.BoxCox)}
+	if loss := choice(g.Loss); loss != "squared" {
+		c.Loss = loss
+	}
+	return c
+}
+
+// Trains a permutation on scaled, d with x scaled by scaling, and scores it by MSE on d, timing its training. Fails
+// if the permutation's target transform cannot apply to d's y
+func Evaluate(d data.InputData, scaled data.InputData, scaling regression.Scaling, permutation Grid, opts TrainOptions) (Result, error) {
+	return evaluate(d, scaled, scaling, permutation, opts, nil)
+}
+
+func evaluate(d data.InputData, scaled data.InputData, scaling regression.Scaling, permutation Grid, opts TrainOptions,
+	epochEnd func(epoch int, parameters regression.Parameters) bool) (Result, error) {
+	start := time.Now()
+	parameters, stats, err := train(scaled, permutation, opts, epochEnd)
+	if err != nil {
+		return Result{}, err
+	}
+	stats.Seconds = time.Since(start).Seconds()
+	parameters = regression.UnScale(parameters, scaling)
+	mse := regression.CalcMSE(Forecast(parameters, d.X, permutation), d.Y)
+	return Result{Config: permutation.config(), Beta: parameters.Beta, Mu: parameters.Mu, MSE: mse, Metric: "mse", Score: mse,
+		Evaluated: 1, Permutation: permutation, Stats: stats}, nil
+}
+
+// Searches every permutation of the grid on d with numThreads goroutines, each taking an equal slice of the
+// permutations as calibrate's workers do, and returns the permutation with the lowest score by the grid's metric. d
+// is only read, so a caller's data is searched in place
+func Search(d data.InputData, grid Grid, numThreads int) (Result, error) {
+	return SearchWithHooks(d, grid, numThreads, nil)
+}
+
+// Searches as Search does, calling the hooks as the search goes. nil hooks are none
+func SearchWithHooks(d data.InputData, grid Grid, numThreads int, hooks *Hooks) (Result, error) {
+	if len(d.X) == 0 || len(d.X) != len(d.Y) {
+		return hooks.taskDone(Result{}, ErrNoData)
+	}
+	permutations, err := grid.Permutations()
+	if err != nil {
+		return hooks.taskDone(Result{}, err)
+	}
+	if len(permutations) == 0 {
+		return hooks.taskDone(Result{}, ErrEmptyGrid)
+	}
+	scale := grid.Scale
+	if scale == "" {
+		scale = "minmax"
+	}
+	if scale != "minmax" && scale != "standard" {
+		return hooks.taskDone(Result{}, fmt.Errorf("gridsearch: unknown scale %s", scale))
+	}
+	metricName := grid.Metric
+	if metricName == "" {
+		metricName = "mse"
+	}
+	metric, ok := regression.LookupMetric(metricName)
+	if !ok {
+		return hooks.taskDone(Result{}, fmt.Errorf("gridsearch: unknown metric %s", metricName))
+	}
+	scaling := FitScaling(d, grid, scale)
+	scaled := regression.Scale(d, scaling)
+	if numThreads < 1 {
+		numThreads = 1
+	}
+	if numThreads > len(permutations) {
+		numThreads = len(permutations)
+	}
+
+	best := Result{MSE: math.Inf(1), Metric: metricName, Score: math.Inf(1), Evaluated: len(permutations)}
+	var lock sync.Mutex
+	var wg sync.WaitGroup
+	var trainErr error
+	sliceSize := (len(permutations) + numThreads - 1) / numThreads
+	for start := 0; start < len(permutations); start += sliceSize {
+		end := start + sliceSize
+		if end > len(permutations) {
+			end = len(permutations)
+		}
+		wg.Add(1)
+		go func(work []Grid) {
+			defer wg.Done()
+			local := Result{MSE: math.Inf(1), Score: math.Inf(1)}
+			for _, permutation := range work {
+				result, err := evaluate(d, scaled, scaling, permutation, TrainOptions{}, hooks.epochEnd(permutation, scaling))
+				if err != nil {
+					lock.Lock()
+					trainErr = err
+					lock.Unlock()
+					return
+				}
+				result.Metric, result.Score = metricName, metric(Forecast(regression.Parameters{Mu: result.Mu, Beta: result.Beta}, d.X,
+					permutation), d.Y)
+				hooks.configDone(result)
+				if result.Score < local.Score {
+					local = result
+				}
+			}
+			lock.Lock()
+			defer lock.Unlock()
+			if local.Score < best.Score {
+				local.Evaluated = best.Evaluated
+				best = local
+			}
+		}(permutations[start:end])
+	}
+	wg.Wait()
+	if trainErr != nil {
+		return hooks.taskDone(Result{}, trainErr)
+	}
+	if math.IsInf(best.Score, 1) {
+		return hooks.taskDone(best, ErrDiverged)
+	}
+	return hooks.taskDone(best, nil)
+}
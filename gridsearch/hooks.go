@@ -0,0 +1,42 @@
+package gridsearch
+
+import "proj3/regression"
+
+// Points of a search that callers subscribe to, eg to prune permutations, draw progress or log metrics, without
+// changing the training loop. Any hook may be nil. The goroutines of a search call them concurrently, so hooks
+// shared between permutations must be safe for concurrent use
+type Hooks struct {
+	// Called after every epoch of a permutation with the parameters so far on the original scale of x. Returning
+	// false stops training the permutation, which is then scored with those parameters
+	OnEpochEnd func(c Config, epoch int, parameters regression.Parameters) bool
+	// Called once a permutation is trained and scored, with its parameters, MSE and training stats; Evaluated is 1
+	OnConfigDone func(r Result)
+	// Called once when the search ends, with the winner and the error Search returns
+	OnTaskDone func(r Result, err error)
+}
+
+// Returns the epoch hook of training a permutation, or nil if there is none, so training skips the call
+func (h *Hooks) epochEnd(permutation Grid, scaling regression.Scaling) func(epoch int, parameters regression.Parameters) bool {
+	if h == nil || h.OnEpochEnd == nil {
+		return nil
+	}
+	c := permutation.config()
+	return func(epoch int, parameters regression.Parameters) bool {
+		return h.OnEpochEnd(c, epoch, regression.UnScale(parameters, scaling))
+	}
+}
+
+func (h *Hooks) configDone(r Result) {
+	if h == nil || h.OnConfigDone == nil {
+		return
+	}
+	h.OnConfigDone(r)
+}
+
+// Calls OnTaskDone and passes the search's outcome through
+func (h *Hooks) taskDone(r Result, err error) (Result, error) {
+	if h != nil && h.OnTaskDone != nil {
+		h.OnTaskDone(r, err)
+	}
+	return r, err
+}
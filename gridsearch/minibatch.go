@@ -0,0 +1,50 @@
+package gridsearch
+
+import (
+	"math/rand"
+	"proj3/data"
+	"time"
+)
+
+// Row order of the mini-batches of a training run. Every epoch shuffles the order in place, so batches are slices of
+// one index permutation and the training data itself is never copied or reordered
+type miniBatches struct {
+	rows data.RowSet
+	size int
+	rng *rand.Rand // nil for a full batch, whose order does not matter
+}
+
+// Creates the mini-batches of a run over n rows. A permutation without a miniBatchSize, or with one at least as large
+// as the data, trains on one full batch that is never shuffled; nil is returned for it when it also minimizes the
+// squared loss with an intercept, which has a faster full batch update. A seed of 0 shuffles with a random seed
+func newMiniBatches(d data.InputData, permutation Grid) *miniBatches {
+	n := len(d.X)
+	if permutation.MiniBatchSize == nil || int(permutation.MiniBatchSize[0]) >= n {
+		if (permutation.Loss == nil || permutation.Loss[0] == "squared") && !permutation.NoIntercept {
+			return nil
+		}
+		return &miniBatches{rows: d.All(), size: n}
+	}
+	size := int(permutation.MiniBatchSize[0])
+	if size < 1 {
+		size = 1
+	}
+	return &miniBatches{rows: d.All(), size: size, rng: SeedRandom(permutation.Seed)}
+}
+
+// Returns the random source of a permutation's or task's seed, or of a random seed if it is 0
+func SeedRandom(seed int64) *rand.Rand {
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return rand.New(rand.NewSource(seed))
+}
+
+// Shuffles the row order for a new epoch and returns its batches. Unshuffled batches of a csv sorted by x or y would
+// each see a biased slice of the data, and so would bias the gradient
+func (b *miniBatches) shuffle() [][]int {
+	if b.rng != nil {
+		b.rows.Permute(b.rng)
+	}
+	return b.rows.Batches(b.size)
+}
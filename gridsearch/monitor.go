@@ -0,0 +1,132 @@
+package gridsearch
+
+import (
+	"math"
+	"proj3/data"
+	"proj3/regression"
+)
+
+// What a training run observed besides its final parameters
+type Stats struct {
+	ConvergedEpoch int // epoch at which the gradient norm fell below the permutation's tolerance, -1 if it never did
+	MaxGradientNorm float64 // largest gradient norm at the start of an epoch, NaN unless tracked
+	BestEpoch int // epoch at which the validation MSE bottomed out, -1 without validation data
+	BestValidationMSE float64 // validation MSE at BestEpoch, NaN without validation data
+	Epochs int // epochs trained, 0 for optimizers that are not monitored per epoch (ransac)
+	Seconds float64 // wall-clock training time, set by Evaluate
+}
+
+// Settings of the per-epoch monitoring of training runs that apply to every permutation of a search
+type TrainOptions struct {
+	TrackGradient bool // track the largest gradient norm
+	Validation *data.InputData // validation data normalized like the training data, nil for none
+	ValidationEvery int // epochs between validation MSE checks, every epoch if below 1
+	RestoreBest bool // end training with the parameters of the best validation epoch rather than the last
+}
+
+// Monitors a training run at the start of every epoch. The run has converged once the norm of the full gradient of
+// its training loss, on the normalized data, falls below the permutation's tolerance. The monitor also tracks the
+// largest gradient norm and the validation MSE when asked to, and stops the run early if its epoch hook says so
+type monitor struct {
+	tolerance float64 // 0 when the permutation has no tolerance, which disables the check
+	options TrainOptions
+	gradient regression.GradientFunc
+	rows []int
+	data data.InputData
+	stats *Stats
+	permutation Grid
+	bestParameters regression.Parameters
+	epochs int // epochs monitored so far
+	epochEnd func(epoch int, parameters regression.Parameters) bool // nil for none
+	stopped bool // by epochEnd
+}
+
+// Creates the monitor of a training run, recording into stats. Gradients are only computed if the permutation has a
+// tolerance or the gradient is tracked, as they cost a pass over the data every epoch
+func newMonitor(dataNormalized data.InputData, permutation Grid, options TrainOptions, stats *Stats,
+	epochEnd func(epoch int, parameters regression.Parameters) bool) *monitor {
+	stats.ConvergedEpoch, stats.MaxGradientNorm = -1, math.NaN()
+	stats.BestEpoch, stats.BestValidationMSE = -1, math.NaN()
+	if options.ValidationEvery < 1 {
+		options.ValidationEvery = 1
+	}
+	m := &monitor{gradient: LossGradient(permutation), options: options, data: dataNormalized, stats: stats,
+		permutation: permutation, epochEnd: epochEnd}
+	if permutation.Tolerance != nil {
+		m.tolerance = permutation.Tolerance[0]
+	}
+	if m.tolerance != 0 || options.TrackGradient {
+		m.rows = make([]int, len(dataNormalized.X))
+		for i := range m.rows {
+			m.rows[i] = i
+		}
+	}
+	return m
+}
+
+// Reports whether the run has converged at the start of the given epoch, recording the epoch if so, or if the epoch
+// hook stopped it after the one before
+func (m *monitor) reached(parameters regression.Parameters, epoch int) bool {
+	if epoch > 0 && m.epochEnd != nil && !m.epochEnd(epoch - 1, parameters) {
+		m.stopped = true
+		return true
+	}
+	m.epochs = epoch + 1
+	m.validate(parameters, epoch, false)
+	if m.rows == nil {
+		return false
+	}
+	gradient := m.gradient(parameters, m.data, m.rows)
+	if m.permutation.Lambda != nil && m.permutation.Lambda[0] > 0 { //the lasso objective cd minimizes, not the plain loss
+		gradient = regression.L1Subgradient(gradient, parameters, m.permutation.Lambda[0])
+	}
+	if m.permutation.NonNegative != "" {
+		gradient = regression.ProjectedGradient(gradient, parameters, m.permutation.NonNegative == "all")
+	}
+	norm := math.Hypot(gradient.Mu, gradient.Beta)
+	if m.options.TrackGradient && !(norm <= m.stats.MaxGradientNorm) { //also replaces the initial NaN, and keeps a diverged NaN or Inf
+		m.stats.MaxGradientNorm = norm
+	}
+	if norm < m.tolerance {
+		m.stats.ConvergedEpoch = epoch
+		return true
+	}
+	return false
+}
+
+// Ends the monitoring of a run with its final parameters, which are validated too unless the run converged (and so
+// was just validated). A run that trained every epoch ends its last one with the epoch hook. Returns the parameters
+// training should end with: those of the best validation epoch with restoreBest, else the final ones
+func (m *monitor) finish(parameters regression.Parameters) regression.Parameters {
+	if m.epochEnd != nil && !m.stopped && m.stats.ConvergedEpoch < 0 && m.epochs > 0 {
+		m.epochEnd(m.epochs - 1, parameters)
+	}
+	m.stats.Epochs = m.epochs
+	if m.stats.ConvergedEpoch >= 0 {
+		m.stats.Epochs = m.stats.ConvergedEpoch
+	}
+	if m.options.Validation == nil {
+		return parameters
+	}
+	if m.stats.ConvergedEpoch < 0 {
+		m.validate(parameters, m.epochs, true)
+	}
+	if m.options.RestoreBest && m.stats.BestEpoch >= 0 {
+		return m.bestParameters
+	}
+	return parameters
+}
+
+// Computes the validation MSE of the parameters at the start of an epoch every validationEvery epochs, or always if
+// force is set, keeping the best
+func (m *monitor) validate(parameters regression.Parameters, epoch int, force bool) {
+	if m.options.Validation == nil || (!force && epoch % m.options.ValidationEvery != 0) {
+		return
+	}
+	validation := m.options.Validation
+	mse := regression.CalcMSE(Forecast(parameters, validation.X, m.permutation), validation.Y)
+	if m.stats.BestEpoch < 0 || mse < m.stats.BestValidationMSE {
+		m.stats.BestEpoch, m.stats.BestValidationMSE = epoch, mse
+		m.bestParameters = parameters
+	}
+}
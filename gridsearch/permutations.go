@@ -0,0 +1,249 @@
+package gridsearch
+
+import (
+	"errors"
+	"fmt"
+	"proj3/regression"
+)
+
+// Momentum of the nag optimizer when a grid does not give a momentum grid
+const DefaultMomentum = 0.9
+
+// Number of past steps the lbfgs optimizer remembers when a grid does not give a history grid
+const DefaultHistory = 5
+
+// Initial step of the linesearch optimizer when a grid does not give an alpha grid
+const DefaultInitialStep = 1.0
+
+// Box-Cox lambda of the boxcox target transform when a grid does not give a boxcox grid, the square root transform
+const DefaultBoxCoxLambda = 0.5
+
+// Optimizers a grid can list
+var optimizers = map[string]bool{"gd": true, "nag": true, "linesearch": true, "cd": true, "cg": true, "lbfgs": true, "ransac": true}
+
+// Alpha schedules a grid can list
+var schedules = map[string]bool{"constant": true, "triangular": true, "cosine": true, "plateau": true}
+
+// Training losses a grid can list, besides those registered with regression.RegisterLoss. poisson and gamma fit
+// generalized linear models by maximum likelihood, so their forecasts go through a link
+var losses = map[string]bool{"squared": true, "epsilon": true, "poisson": true, "gamma": true}
+
+// Transforms of y a grid can list
+var targets = map[string]bool{"identity": true, "log": true, "boxcox": true}
+
+// Returns why the grid cannot be searched, or nil if it can: an optimizer, schedule, loss, link or target transform it
+// does not know, an optimizer its other settings rule out, or ransac without a threshold grid
+func (g Grid) Check() error {
+	for _, optimizer := range g.Optimizer {
+		if !optimizers[optimizer] {
+			return fmt.Errorf("gridsearch: unknown optimizer %s", optimizer)
+		}
+		if (g.NoIntercept || g.NonNegative != "") && optimizer != "gd" && optimizer != "nag" {
+			return fmt.Errorf("gridsearch: fitIntercept false and nonNegative need the gd or nag optimizer, not %s", optimizer)
+		}
+		if optimizer == "ransac" && len(g.Threshold) == 0 {
+			return errors.New("gridsearch: the ransac optimizer needs a threshold grid")
+		}
+	}
+	for _, schedule := range g.Schedule {
+		if !schedules[schedule] {
+			return fmt.Errorf("gridsearch: unknown schedule %s", schedule)
+		}
+	}
+	for _, loss := range g.Loss {
+		if _, registered := regression.LookupLoss(loss); !losses[loss] && !registered {
+			return fmt.Errorf("gridsearch: unknown loss %s", loss)
+		}
+	}
+	for _, link := range g.Link {
+		if _, ok := regression.Links[link]; !ok {
+			return fmt.Errorf("gridsearch: unknown link %s", link)
+		}
+	}
+	for _, target := range g.Target {
+		if !targets[target] {
+			return fmt.Errorf("gridsearch: unknown target transform %s", target)
+		}
+	}
+	if g.NonNegative != "" && g.NonNegative != "beta" && g.NonNegative != "all" {
+		return fmt.Errorf("gridsearch: invalid nonNegative %s, expected beta or all", g.NonNegative)
+	}
+	return nil
+}
+
+// Returns every permutation of the grid, or an error if Check rejects it. Dimensions that only apply to some
+// optimizers, like momentum, are only expanded for those optimizers, and a grid without an optimizer uses gd. An empty
+// dimension an optimizer needs, such as its numEpochs, leaves it no permutations
+func (g Grid) Permutations() ([]Grid, error) {
+	if err := g.Check(); err != nil {
+		return nil, err
+	}
+	optimizerGrid := g.Optimizer
+	if len(optimizerGrid) == 0 {
+		optimizerGrid = []string{"gd"}
+	}
+	output := make([]Grid, 0, 0)
+	for _, optimizer := range optimizerGrid {
+		permutations := []Grid{{Optimizer: []string{optimizer}, Seed: g.Seed, NoIntercept: g.NoIntercept, NonNegative: g.NonNegative}}
+		//line search picks its own step, alpha is only its optional initial step. Coordinate descent and conjugate
+		//gradient minimize exactly along each direction and have no step, L-BFGS line searches from a unit step and
+		//RANSAC fits least squares in closed form
+		if optimizer != "cd" && optimizer != "cg" && optimizer != "lbfgs" && optimizer != "ransac" && (optimizer != "linesearch" || len(g.Alpha) > 0) {
+			permutations = expandDimension(permutations, g.Alpha, func(p *Grid, value float64) { p.Alpha = []float64{value} })
+		}
+		permutations = expandDimension(permutations, g.NumEpochs, func(p *Grid, value float64) { p.NumEpochs = []float64{value} })
+		if optimizer == "nag" {
+			momentum := g.Momentum
+			if len(momentum) == 0 {
+				momentum = []float64{DefaultMomentum}
+			}
+			permutations = expandDimension(permutations, momentum, func(p *Grid, value float64) { p.Momentum = []float64{value} })
+		}
+		if (optimizer == "gd" || optimizer == "nag") && len(g.Warmup) > 0 { //without a warmup grid alpha is constant
+			permutations = expandDimension(permutations, g.Warmup, func(p *Grid, value float64) { p.Warmup = []float64{value} })
+		}
+		if (optimizer == "gd" || optimizer == "nag") && len(g.MiniBatchSize) > 0 { //without a batch grid every epoch is one full batch
+			permutations = expandDimension(permutations, g.MiniBatchSize, func(p *Grid, value float64) { p.MiniBatchSize = []float64{value} })
+		}
+		if (optimizer == "gd" || optimizer == "nag") && len(g.Loss) > 0 { //without a loss grid training minimizes the squared loss
+			permutations = g.expandLosses(permutations)
+		}
+		if (optimizer == "gd" || optimizer == "nag") && len(g.Schedule) > 0 { //without a schedule grid alpha is constant
+			permutations = g.expandSchedules(permutations)
+		}
+		if optimizer == "lbfgs" {
+			history := g.History
+			if len(history) == 0 {
+				history = []float64{DefaultHistory}
+			}
+			permutations = expandDimension(permutations, history, func(p *Grid, value float64) { p.History = []float64{value} })
+		}
+		if optimizer != "ransac" && len(g.Tolerance) > 0 { //without a tolerance every epoch runs
+			permutations = expandDimension(permutations, g.Tolerance, func(p *Grid, value float64) { p.Tolerance = []float64{value} })
+		}
+		if optimizer == "ransac" {
+			permutations = expandDimension(permutations, g.Threshold, func(p *Grid, value float64) { p.Threshold = []float64{value} })
+		}
+		if len(g.Target) > 0 { //without a target grid training fits y itself
+			permutations = g.expandTargets(permutations)
+		}
+		if optimizer == "cd" {
+			lambda := g.Lambda
+			if len(lambda) == 0 {
+				lambda = []float64{0}
+			}
+			permutations = expandDimension(permutations, lambda, func(p *Grid, value float64) { p.Lambda = []float64{value} })
+		}
+		output = append(output, permutations...)
+	}
+	return output, nil
+}
+
+// Expands every permutation by each training loss of the grid. The epsilon loss is further expanded by its epsilon
+// grid (default 0, the absolute loss), and the generalized linear model losses by their link grid (default log)
+func (g Grid) expandLosses(permutations []Grid) []Grid {
+	epsilon := g.Epsilon
+	if len(epsilon) == 0 {
+		epsilon = []float64{0}
+	}
+	links := g.Link
+	if len(links) == 0 {
+		links = []string{"log"}
+	}
+	output := make([]Grid, 0)
+	for _, loss := range g.Loss {
+		withLoss := make([]Grid, 0, len(permutations))
+		for _, permutation := range permutations {
+			permutation.Loss = []string{loss}
+			withLoss = append(withLoss, permutation)
+		}
+		if loss == "epsilon" {
+			withLoss = expandDimension(withLoss, epsilon, func(p *Grid, value float64) { p.Epsilon = []float64{value} })
+		}
+		if IsGLM(loss) {
+			withLinks := make([]Grid, 0, len(withLoss) * len(links))
+			for _, permutation := range withLoss {
+				for _, link := range links {
+					permutation.Link = []string{link}
+					withLinks = append(withLinks, permutation)
+				}
+			}
+			withLoss = withLinks
+		}
+		output = append(output, withLoss...)
+	}
+	return output
+}
+
+// Expands every permutation by each target transform of the grid. The boxcox transform is further expanded by its
+// lambda grid (default 0.5)
+func (g Grid) expandTargets(permutations []Grid) []Grid {
+	boxCox := g.BoxCox
+	if len(boxCox) == 0 {
+		boxCox = []float64{DefaultBoxCoxLambda}
+	}
+	output := make([]Grid, 0)
+	for _, target := range g.Target {
+		withTarget := make([]Grid, 0, len(permutations))
+		for _, permutation := range permutations {
+			permutation.Target = []string{target}
+			withTarget = append(withTarget, permutation)
+		}
+		if target == "boxcox" {
+			withTarget = expandDimension(withTarget, boxCox, func(p *Grid, value float64) { p.BoxCox = []float64{value} })
+		}
+		output = append(output, withTarget...)
+	}
+	return output
+}
+
+// Expands every permutation by each alpha schedule of the grid. Cyclical schedules are further expanded by their
+// minAlpha (default 0) and cycle grids, the plateau schedule by its factor (default 0.5) and patience (default 10)
+// grids, and the constant schedule has none
+func (g Grid) expandSchedules(permutations []Grid) []Grid {
+	minAlpha := g.MinAlpha
+	if len(minAlpha) == 0 {
+		minAlpha = []float64{0}
+	}
+	factor := g.Factor
+	if len(factor) == 0 {
+		factor = []float64{DefaultPlateauFactor}
+	}
+	patience := g.Patience
+	if len(patience) == 0 {
+		patience = []float64{DefaultPlateauPatience}
+	}
+	output := make([]Grid, 0)
+	for _, schedule := range g.Schedule {
+		scheduled := make([]Grid, 0, len(permutations))
+		for _, permutation := range permutations {
+			permutation.Schedule = []string{schedule}
+			scheduled = append(scheduled, permutation)
+		}
+		if schedule == "plateau" {
+			scheduled = expandDimension(scheduled, factor, func(p *Grid, value float64) { p.Factor = []float64{value} })
+			scheduled = expandDimension(scheduled, patience, func(p *Grid, value float64) { p.Patience = []float64{value} })
+		} else if schedule != "constant" {
+			scheduled = expandDimension(scheduled, minAlpha, func(p *Grid, value float64) { p.MinAlpha = []float64{value} })
+			if len(g.Cycle) > 0 { //without a cycle grid the whole run is one cycle
+				scheduled = expandDimension(scheduled, g.Cycle, func(p *Grid, value float64) { p.Cycle = []float64{value} })
+			}
+		}
+		output = append(output, scheduled...)
+	}
+	return output
+}
+
+// Expands every permutation by each value of a hyperparameter dimension. An empty dimension has nothing to search,
+// so it yields no permutations
+func expandDimension(permutations []Grid, values []float64, set func(p *Grid, value float64)) []Grid {
+	output := make([]Grid, 0, len(permutations) * len(values))
+	for _, permutation := range permutations {
+		for _, value := range values {
+			expanded := permutation
+			set(&expanded, value)
+			output = append(output, expanded)
+		}
+	}
+	return output
+}
@@ -0,0 +1,53 @@
+package gridsearch
+
+import (
+	"proj3/data"
+	"proj3/regression"
+)
+
+// Factor and patience of the plateau schedule when a grid does not give their grids
+const (
+	DefaultPlateauFactor = 0.5
+	DefaultPlateauPatience = 10
+)
+
+// State of the plateau schedule of a training run, which multiplies alpha by factor every time the training loss on
+// the normalized data, the one its gradient descends, has not improved for patience epochs. It rescues alphas that are
+// fine at first but end up oscillating around the minimum
+type plateau struct {
+	factor float64
+	patience int
+	scale float64 // product of the cuts so far
+	bestLoss float64
+	wait int // epochs since bestLoss last improved
+	data data.InputData
+	permutation Grid
+}
+
+// Creates the plateau state of a training run, or returns nil if the permutation does not use the plateau schedule
+func newPlateau(dataNormalized data.InputData, permutation Grid) *plateau {
+	if permutation.Schedule == nil || permutation.Schedule[0] != "plateau" {
+		return nil
+	}
+	return &plateau{factor: permutation.Factor[0], patience: int(permutation.Patience[0]), scale: 1, bestLoss: -1, data: dataNormalized,
+		permutation: permutation}
+}
+
+// Returns the alpha of the coming epoch: the scheduled alpha scaled by the cuts so far, after cutting again if the
+// current parameters have not improved the training loss for patience epochs. A nil plateau leaves alpha as is
+func (p *plateau) adjust(parameters regression.Parameters, alpha float64) float64 {
+	if p == nil {
+		return alpha
+	}
+	loss := TrainingLoss(parameters, p.data, p.permutation)
+	if p.bestLoss < 0 || loss < p.bestLoss {
+		p.bestLoss, p.wait = loss, 0
+	} else {
+		p.wait++
+		if p.wait >= p.patience {
+			p.scale *= p.factor
+			p.wait = 0
+		}
+	}
+	return alpha * p.scale
+}
@@ -0,0 +1,235 @@
+package gridsearch
+
+import (
+	"fmt"
+	"math"
+	"proj3/data"
+	"proj3/regression"
+)
+
+// Calibrates regression coefficients of one permutation on data whose x is already scaled, returning them on that
+// scale. The optimizer is plain gradient descent (gd), Nesterov accelerated gradient (nag), gradient descent with a
+// backtracking line search (linesearch) or coordinate descent with an L1 penalty (cd), for which numEpochs counts
+// sweeps over mu and beta, or linear conjugate gradient on the least squares objective (cg), for which numEpochs counts
+// Krylov iterations, L-BFGS (lbfgs), or random sample consensus (ransac), for which numEpochs counts the random lines
+// tried. Iterative optimizers stop early once the gradient norm falls below the permutation's tolerance. The returned
+// stats record that epoch, and as opts ask, the largest gradient norm and the epoch of the lowest validation MSE.
+// Fails if the permutation's target transform cannot apply to y
+func Train(scaled data.InputData, permutation Grid, opts TrainOptions) (regression.Parameters, Stats, error) {
+	return train(scaled, permutation, opts, nil)
+}
+
+// Trains a permutation as Train does, calling epochEnd with the parameters after every epoch. Training stops early
+// once epochEnd returns false. A nil epochEnd trains every epoch
+func train(scaled data.InputData, permutation Grid, opts TrainOptions,
+	epochEnd func(epoch int, parameters regression.Parameters) bool) (regression.Parameters, Stats, error) {
+	var stats Stats
+	scaled, err := TrainingTarget(scaled, permutation)
+	if err != nil {
+		return regression.Parameters{}, stats, err
+	}
+	parameters := InitialParameters(scaled, permutation)
+	numEpochs := permutation.NumEpochs[0]
+	m := newMonitor(scaled, permutation, opts, &stats, epochEnd)
+	switch permutation.Optimizer[0] {
+	case "nag":
+		velocity := regression.Parameters{0, 0}
+		batches := newMiniBatches(scaled, permutation)
+		plateau := newPlateau(scaled, permutation)
+		for i:=0; i < int(numEpochs) && !m.reached(parameters, i); i++{
+			alpha := plateau.adjust(parameters, ScheduledAlpha(permutation, i))
+			if batches == nil {
+				parameters, velocity = regression.UpdateParamsNesterov(parameters, velocity, scaled, alpha, permutation.Momentum[0])
+				parameters = Constrain(parameters, permutation)
+				continue
+			}
+			for _, rows := range batches.shuffle() {
+				parameters, velocity = regression.UpdateParamsNesterovRows(parameters, velocity, scaled, rows, alpha, permutation.Momentum[0],
+					LossGradient(permutation))
+				parameters = Constrain(parameters, permutation)
+			}
+		}
+	case "linesearch":
+		initialStep := DefaultInitialStep
+		if permutation.Alpha != nil {
+			initialStep = permutation.Alpha[0]
+		}
+		for i:=0; i < int(numEpochs) && !m.reached(parameters, i); i++{
+			parameters, _ = regression.UpdateParamsLineSearch(parameters, scaled, initialStep)
+		}
+	case "cg":
+		var residual, direction regression.Parameters //zero direction starts conjugate gradient from steepest descent
+		for i:=0; i < int(numEpochs) && !m.reached(parameters, i); i++{
+			parameters, residual, direction = regression.UpdateParamsConjugateGradient(parameters, residual, direction, scaled)
+		}
+	case "lbfgs":
+		memory := regression.NewLBFGSMemory(int(permutation.History[0]))
+		for i:=0; i < int(numEpochs) && !m.reached(parameters, i); i++{
+			parameters, memory = regression.UpdateParamsLBFGS(parameters, memory, scaled)
+		}
+	case "ransac":
+		parameters = regression.RANSAC(scaled, int(numEpochs), permutation.Threshold[0], SeedRandom(permutation.Seed))
+	case "cd":
+		for i:=0; i < int(numEpochs) && !m.reached(parameters, i); i++{
+			parameters = regression.UpdateParamsCoordinate(parameters, scaled, permutation.Lambda[0])
+		}
+	default:
+		batches := newMiniBatches(scaled, permutation)
+		plateau := newPlateau(scaled, permutation)
+		for i:=0; i < int(numEpochs) && !m.reached(parameters, i); i++{
+			alpha := plateau.adjust(parameters, ScheduledAlpha(permutation, i))
+			if batches == nil {
+				parameters = Constrain(regression.UpdateParams(parameters, scaled, alpha), permutation)
+				continue
+			}
+			for _, rows := range batches.shuffle() {
+				parameters = Constrain(regression.UpdateParamsRows(parameters, scaled, rows, alpha, LossGradient(permutation)), permutation)
+			}
+		}
+	}
+	return m.finish(parameters), stats, nil
+}
+
+// Fits the scaling of x by the scale method, minmax or standard. Permutations without an intercept or with a
+// non-negative mu scale without centering, so that mu is the same once transformed back
+func FitScaling(d data.InputData, permutation Grid, scale string) regression.Scaling {
+	scaling := regression.FitScaling(d, scale)
+	if permutation.NoIntercept || permutation.NonNegative == "all" {
+		return scaling.Uncentered()
+	}
+	return scaling
+}
+
+// Projects parameters onto the permutation's nonNegative constraint after an update, leaving them as is without one
+func Constrain(parameters regression.Parameters, permutation Grid) regression.Parameters {
+	if permutation.NonNegative == "" {
+		return parameters
+	}
+	return regression.ProjectNonNegative(parameters, permutation.NonNegative == "all")
+}
+
+// Returns the gradient of the training loss of a permutation. Whatever the training loss, permutations are compared by
+// MSE, so the winner is the one that predicts best
+func LossGradient(permutation Grid) regression.GradientFunc {
+	gradient := regression.GradientFunc(regression.GradientRows)
+	if permutation.Loss != nil {
+		switch loss := permutation.Loss[0]; {
+		case loss == "epsilon":
+			gradient = regression.EpsilonInsensitiveGradient(permutation.Epsilon[0])
+		case IsGLM(loss):
+			gradient = regression.GLMGradient(regression.Families[loss], regression.Links[permutation.Link[0]])
+		case loss != "squared":
+			registered, _ := regression.LookupLoss(loss)
+			gradient = registered.Gradient
+		}
+	}
+	if permutation.NoIntercept {
+		return regression.WithoutIntercept(gradient)
+	}
+	return gradient
+}
+
+// Returns the training loss of a permutation on the data, the objective LossGradient descends: the MSE of the linear
+// forecast, the epsilon-insensitive or a registered loss of it, or the deviance of a generalized linear model's
+// forecast through its link
+func TrainingLoss(parameters regression.Parameters, d data.InputData, permutation Grid) float64 {
+	loss := "squared"
+	if permutation.Loss != nil {
+		loss = permutation.Loss[0]
+	}
+	if IsGLM(loss) {
+		predicted := regression.ForecastGLM(parameters.Mu, parameters.Beta, d.X, regression.Links[permutation.Link[0]])
+		return regression.CalcDeviance(predicted, d.Y, regression.Deviances[loss])
+	}
+	predicted := regression.Forecast(parameters.Mu, parameters.Beta, d.X)
+	switch loss {
+	case "squared":
+		return regression.CalcMSE(predicted, d.Y)
+	case "epsilon":
+		return regression.EpsilonInsensitiveLoss(predicted, d.Y, permutation.Epsilon[0])
+	}
+	registered, _ := regression.LookupLoss(loss)
+	return registered.Value(predicted, d.Y)
+}
+
+// Reports whether a training loss fits a generalized linear model
+func IsGLM(loss string) bool {
+	return loss == "poisson" || loss == "gamma"
+}
+
+// Returns the starting parameters of gradient descent: all 0, except for generalized linear models with an intercept,
+// which start from a flat fit as eta = 0 is outside the domain of the inverse link
+func InitialParameters(scaled data.InputData, permutation Grid) regression.Parameters {
+	if permutation.Loss != nil && IsGLM(permutation.Loss[0]) && !permutation.NoIntercept {
+		return regression.InitGLM(scaled, regression.Links[permutation.Link[0]])
+	}
+	return regression.Parameters{0, 0}
+}
+
+// Forecasts y given calibrated parameters, through the link of a generalized linear model if the permutation fits one,
+// and back through the inverse of its target transform if it has one
+func Forecast(parameters regression.Parameters, x []float64, permutation Grid) []float64 {
+	var forecasts []float64
+	if permutation.Loss != nil && IsGLM(permutation.Loss[0]) {
+		forecasts = regression.ForecastGLM(parameters.Mu, parameters.Beta, x, regression.Links[permutation.Link[0]])
+	} else {
+		forecasts = regression.Forecast(parameters.Mu, parameters.Beta, x)
+	}
+	if transform, ok := TargetTransform(permutation); ok {
+		return regression.InverseTransform(forecasts, transform)
+	}
+	return forecasts
+}
+
+// Returns the transformation of y a permutation trains on, and false if it trains on y itself
+func TargetTransform(permutation Grid) (regression.TargetTransform, bool) {
+	if permutation.Target == nil || permutation.Target[0] == "identity" {
+		return regression.TargetTransform{}, false
+	}
+	if permutation.Target[0] == "log" {
+		return regression.BoxCox(0), true
+	}
+	return regression.BoxCox(permutation.BoxCox[0]), true
+}
+
+// Returns the data a permutation is trained on: the data itself, or a copy with y transformed if the permutation has a
+// target transform, which needs strictly positive y
+func TrainingTarget(d data.InputData, permutation Grid) (data.InputData, error) {
+	transform, ok := TargetTransform(permutation)
+	if !ok {
+		return d, nil
+	}
+	for _, y := range d.Y {
+		if !(y > 0) {
+			return d, fmt.Errorf("gridsearch: the %s target transform needs strictly positive y, got %v", permutation.Target[0], y)
+		}
+	}
+	return regression.TransformTarget(d, transform), nil
+}
+
+// Returns the alpha used in a given epoch (counting from 0). Cyclical schedules move alpha between minAlpha and the
+// permutation's alpha every cycle: triangular rises linearly to alpha mid cycle and falls back, cosine anneals from
+// alpha down to minAlpha and restarts. The plateau schedule adapts alpha to the loss instead, see plateau. During a
+// warmup of k epochs the scheduled alpha is scaled up linearly, reaching its full value at epoch k-1, which keeps
+// aggressive alphas from blowing up the first updates
+func ScheduledAlpha(permutation Grid, epoch int) float64 {
+	alpha := permutation.Alpha[0]
+	if permutation.Schedule != nil && (permutation.Schedule[0] == "triangular" || permutation.Schedule[0] == "cosine") {
+		cycle := permutation.NumEpochs[0]
+		if permutation.Cycle != nil {
+			cycle = permutation.Cycle[0]
+		}
+		position := math.Mod(float64(epoch), cycle) / cycle
+		minAlpha := permutation.MinAlpha[0]
+		switch permutation.Schedule[0] {
+		case "triangular":
+			alpha = minAlpha + (alpha - minAlpha) * (1 - math.Abs(2 * position - 1))
+		case "cosine":
+			alpha = minAlpha + (alpha - minAlpha) * (1 + math.Cos(math.Pi * position)) / 2
+		}
+	}
+	if permutation.Warmup != nil && float64(epoch) < permutation.Warmup[0] {
+		return alpha * float64(epoch + 1) / permutation.Warmup[0]
+	}
+	return alpha
+}
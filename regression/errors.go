@@ -0,0 +1,63 @@
+package regression
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"proj3/data"
+)
+
+// Failures of fitting a model that library callers and the CLI can tell apart with errors.Is rather than by message
+var (
+	ErrConstantFeature = errors.New("regression: constant feature")
+	ErrDiverged = errors.New("regression: training diverged")
+)
+
+// Checks data can be fitted: data.ErrEmptyData if it has no rows, and ErrConstantFeature naming the first column of
+// x or Features that takes a single value, whose coefficient cannot be told apart from the intercept. Missing (NaN)
+// values are ignored, and nil is returned otherwise
+func CheckData(d data.InputData) error {
+	if len(d.X) == 0 {
+		return data.ErrEmptyData
+	}
+	if isConstant(d.X) {
+		return fmt.Errorf("%w x", ErrConstantFeature)
+	}
+	for i, feature := range d.Features {
+		if !isConstant(feature) {
+			continue
+		}
+		name := fmt.Sprintf("%d", i + 1)
+		if i < len(d.FeatureNames) {
+			name = d.FeatureNames[i]
+		}
+		return fmt.Errorf("%w %s", ErrConstantFeature, name)
+	}
+	return nil
+}
+
+// Whether the observed values of a column are all the same
+func isConstant(column []float64) bool {
+	first := math.NaN()
+	for _, value := range column {
+		if math.IsNaN(value) {
+			continue
+		}
+		if math.IsNaN(first) {
+			first = value
+		} else if value != first {
+			return false
+		}
+	}
+	return true
+}
+
+// Returns ErrDiverged if training left the parameters or the loss they reach not finite, else nil
+func CheckDiverged(parameters Parameters, loss float64) error {
+	for _, value := range []float64{parameters.Mu, parameters.Beta, loss} {
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			return ErrDiverged
+		}
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package regression
+
+import (
+	"math"
+	"proj3/data"
+)
+
+// Link function g of a generalized linear model g(E[y]) = mu + beta*x, given by g itself, its inverse and the
+// derivative of its inverse with respect to the linear predictor eta = mu + beta*x
+type Link struct {
+	Apply func(mean float64) float64
+	Inverse func(eta float64) float64
+	InverseDerivative func(eta float64) float64
+}
+
+// Links a generalized linear model can use
+var Links = map[string]Link{
+	"identity": {
+		Apply: func(mean float64) float64 { return mean },
+		Inverse: func(eta float64) float64 { return eta },
+		InverseDerivative: func(eta float64) float64 { return 1 },
+	},
+	"log": {
+		Apply: math.Log,
+		Inverse: math.Exp,
+		InverseDerivative: math.Exp,
+	},
+	"inverse": {
+		Apply: func(mean float64) float64 { return 1 / mean },
+		Inverse: func(eta float64) float64 { return 1 / eta },
+		InverseDerivative: func(eta float64) float64 { return -1 / (eta * eta) },
+	},
+}
+
+// Variance functions V(mean) of the exponential families a generalized linear model can use: Poisson for counts and
+// Gamma for positive durations or amounts, whose spread grows with their mean
+var Families = map[string]func(mean float64) float64{
+	"poisson": func(mean float64) float64 { return mean },
+	"gamma": func(mean float64) float64 { return mean * mean },
+}
+
+// Unit deviances d(y, mean) of the exponential families, twice the log likelihood lost against a perfect fit. Half
+// their mean is the objective GLMGradient descends
+var Deviances = map[string]func(y float64, mean float64) float64{
+	"poisson": func(y float64, mean float64) float64 {
+		if y == 0 {
+			return 2 * mean
+		}
+		return 2 * (y * math.Log(y / mean) - (y - mean))
+	},
+	"gamma": func(y float64, mean float64) float64 { return 2 * (-math.Log(y / mean) + (y - mean) / mean) },
+}
+
+// Calculates the mean deviance of the means a generalized linear model predicts under the given unit deviance
+func CalcDeviance(predicted []float64, actual []float64, deviance func(y float64, mean float64) float64) float64 {
+	total := float64(0)
+	for i := 0; i < len(predicted); i++ {
+		total += deviance(actual[i], predicted[i])
+	}
+	return total / float64(len(predicted))
+}
+
+// Forecasts a generalized linear model, ie the inverse link of the linear forecast
+func ForecastGLM(mu float64, beta float64, x []float64, link Link) []float64 {
+	predicted := Forecast(mu, beta, x)
+	for i := range predicted {
+		predicted[i] = link.Inverse(predicted[i])
+	}
+	return predicted
+}
+
+// Returns the gradient of the negative log likelihood of a generalized linear model with the given variance function
+// and link, which per row is (mean - y) / V(mean) * dmean/deta times (1, x)
+func GLMGradient(variance func(mean float64) float64, link Link) GradientFunc {
+	return func(parameters Parameters, data data.InputData, rows []int) Parameters {
+		gradient := Parameters{0, 0}
+		for _, row := range rows {
+			eta := parameters.Beta * data.X[row] + parameters.Mu
+			mean := link.Inverse(eta)
+			weight := (mean - data.Y[row]) / variance(mean) * link.InverseDerivative(eta)
+			gradient.Mu += weight
+			gradient.Beta += weight * data.X[row]
+		}
+		n := float64(len(rows))
+		return Parameters{gradient.Mu / n, gradient.Beta / n}
+	}
+}
+
+// Returns the starting parameters of a generalized linear model: a flat fit at the mean of y, which unlike all zeros
+// is inside the domain of every link
+func InitGLM(data data.InputData, link Link) Parameters {
+	return Parameters{link.Apply(Mean(data.Y)), 0}
+}
@@ -0,0 +1,124 @@
+package regression
+
+import (
+	"math"
+	"proj3/data"
+)
+
+// Two sided 95% quantile of the standard normal distribution
+const normalQuantile975 = 1.959963984540054
+
+// Calculates the ordinary least squares standard errors of mu and beta from the residual variance
+// sum((y - yhat)^2) / (n - 2). They assume independent residuals of constant variance, and are NaN with fewer than
+// 3 rows or a constant x
+func StandardErrors(parameters Parameters, data data.InputData) Parameters {
+	n := float64(len(data.X))
+	if n < 3 {
+		return Parameters{math.NaN(), math.NaN()}
+	}
+	predicted := Forecast(parameters.Mu, parameters.Beta, data.X)
+	residualVariance := CalcMSE(predicted, data.Y) * n / (n - 2)
+	meanX := Mean(data.X)
+	sumSquaresX := float64(0)
+	for _, x := range data.X {
+		sumSquaresX += (x - meanX) * (x - meanX)
+	}
+	if sumSquaresX == 0 {
+		return Parameters{math.NaN(), math.NaN()}
+	}
+	return Parameters{math.Sqrt(residualVariance * (1 / n + meanX * meanX / sumSquaresX)), math.Sqrt(residualVariance / sumSquaresX)}
+}
+
+// Returns the two sided 95% quantile of Student's t distribution with df degrees of freedom, by the Cornish-Fisher
+// expansion around the normal quantile, which is within 0.003 of it from df = 5 and exact in the limit
+func TQuantile975(df float64) float64 {
+	z := normalQuantile975
+	z3, z5, z7 := math.Pow(z, 3), math.Pow(z, 5), math.Pow(z, 7)
+	return z + (z3 + z) / (4 * df) + (5 * z5 + 16 * z3 + 3 * z) / (96 * df * df) +
+		(3 * z7 + 19 * z5 + 17 * z3 - 15 * z) / (384 * df * df * df)
+}
+
+// Returns the 95% confidence intervals of mu and beta as (low, high) parameters, estimate -/+ t * standard error
+func ConfidenceIntervals(parameters Parameters, standardErrors Parameters, n int) (Parameters, Parameters) {
+	t := TQuantile975(float64(n - 2))
+	low := Parameters{parameters.Mu - t * standardErrors.Mu, parameters.Beta - t * standardErrors.Beta}
+	high := Parameters{parameters.Mu + t * standardErrors.Mu, parameters.Beta + t * standardErrors.Beta}
+	return low, high
+}
+
+// Calculates the internally studentized residuals of a linear fit, each residual divided by its own standard error
+// sigma * sqrt(1 - leverage), where the leverage of a row grows with its distance from the mean of x. Values beyond
+// about 3 in absolute value flag outliers
+func StandardizedResiduals(parameters Parameters, data data.InputData) []float64 {
+	n := float64(len(data.X))
+	predicted := Forecast(parameters.Mu, parameters.Beta, data.X)
+	sigma := math.Sqrt(CalcMSE(predicted, data.Y) * n / (n - 2))
+	meanX := Mean(data.X)
+	sumSquaresX := float64(0)
+	for _, x := range data.X {
+		sumSquaresX += (x - meanX) * (x - meanX)
+	}
+	standardized := make([]float64, len(data.X))
+	for i, x := range data.X {
+		leverage := 1 / n + (x - meanX) * (x - meanX) / sumSquaresX
+		standardized[i] = (data.Y[i] - predicted[i]) / (sigma * math.Sqrt(1 - leverage))
+	}
+	return standardized
+}
+
+// Calculates the sample skewness of a slice, 0 for symmetric data such as normal residuals
+func Skewness(arr []float64) float64 {
+	mean, std := Mean(arr), populationStdDev(arr)
+	sum := float64(0)
+	for _, value := range arr {
+		sum += math.Pow((value - mean) / std, 3)
+	}
+	return sum / float64(len(arr))
+}
+
+// Calculates the sample excess kurtosis of a slice, 0 for normal data and positive for heavy tailed data
+func Kurtosis(arr []float64) float64 {
+	mean, std := Mean(arr), populationStdDev(arr)
+	sum := float64(0)
+	for _, value := range arr {
+		sum += math.Pow((value - mean) / std, 4)
+	}
+	return sum / float64(len(arr)) - 3
+}
+
+// Calculates the population standard deviation of a slice, the scale of the moment based skewness and kurtosis
+func populationStdDev(arr []float64) float64 {
+	mean := Mean(arr)
+	sumSquares := float64(0)
+	for _, value := range arr {
+		sumSquares += (value - mean) * (value - mean)
+	}
+	return math.Sqrt(sumSquares / float64(len(arr)))
+}
+
+// Calculates the Durbin-Watson statistic sum((e_t - e_t-1)^2) / sum(e_t^2) of residuals in time order. It is about 2
+// for independent residuals, towards 0 for positive and towards 4 for negative autocorrelation
+func DurbinWatson(residuals []float64) float64 {
+	differences, squares := float64(0), float64(0)
+	for t, residual := range residuals {
+		squares += residual * residual
+		if t > 0 {
+			differences += (residual - residuals[t - 1]) * (residual - residuals[t - 1])
+		}
+	}
+	return differences / squares
+}
+
+// Calculates the autocorrelation of a series at the given lag, ie the correlation of each value with the value lag
+// steps earlier, around the mean of the whole series
+func Autocorrelation(series []float64, lag int) float64 {
+	mean := Mean(series)
+	covariance, variance := float64(0), float64(0)
+	for t, value := range series {
+		variance += (value - mean) * (value - mean)
+		if t >= lag {
+			covariance += (value - mean) * (series[t - lag] - mean)
+		}
+	}
+	return covariance / variance
+}
@@ -0,0 +1,126 @@
+package regression
+
+import (
+	"errors"
+	"math"
+	"proj3/data"
+	"sync"
+)
+
+// Gradient of a training loss over the given rows of the data
+type GradientFunc func(parameters Parameters, data data.InputData, rows []int) Parameters
+
+// A training loss of the linear forecast mu + beta * x: its mean over predictions, and its gradient with respect to
+// mu and beta over the given rows, which gradient descent follows. Programs embedding the search register their own
+// with RegisterLoss
+type Loss interface {
+	Value(predicted []float64, actual []float64) float64
+	Gradient(parameters Parameters, data data.InputData, rows []int) Parameters
+}
+
+// Names of the losses built into the search, which cannot be registered
+var builtinLosses = map[string]bool{"squared": true, "epsilon": true, "poisson": true, "gamma": true}
+
+// Losses registered by name
+var registeredLosses = struct {
+	sync.RWMutex
+	losses map[string]Loss
+}{losses: make(map[string]Loss)}
+
+// Registers a loss under a name, so tasks can list it in their "loss" grid like the built in ones. A name can only
+// be registered once, and not as that of a built in loss
+func RegisterLoss(name string, loss Loss) error {
+	if name == "" || loss == nil {
+		return errors.New("regression: a loss needs a name and an implementation")
+	}
+	registeredLosses.Lock()
+	defer registeredLosses.Unlock()
+	if _, ok := registeredLosses.losses[name]; ok || builtinLosses[name] {
+		return errors.New("regression: loss " + name + " is already registered")
+	}
+	registeredLosses.losses[name] = loss
+	return nil
+}
+
+// Returns the loss registered under a name, and false if there is none
+func LookupLoss(name string) (Loss, bool) {
+	registeredLosses.RLock()
+	defer registeredLosses.RUnlock()
+	loss, ok := registeredLosses.losses[name]
+	return loss, ok
+}
+
+// Calculates the epsilon-insensitive loss mean(max(0, |y - yhat| - epsilon)) of support vector regression, which
+// does not penalize residuals within epsilon of 0 at all and grows linearly outside
+func EpsilonInsensitiveLoss(predicted []float64, actual []float64, epsilon float64) float64 {
+	loss := float64(0)
+	for i := 0; i < len(predicted); i++ {
+		loss += math.Max(0, math.Abs(actual[i] - predicted[i]) - epsilon)
+	}
+	return loss / float64(len(predicted))
+}
+
+// Returns the gradient of the epsilon-insensitive loss. Only rows whose residual is outside epsilon contribute, each
+// pulling the fit towards it by the sign of its residual
+func EpsilonInsensitiveGradient(epsilon float64) GradientFunc {
+	return func(parameters Parameters, data data.InputData, rows []int) Parameters {
+		gradient := Parameters{0, 0}
+		for _, row := range rows {
+			residual := data.Y[row] - (parameters.Beta * data.X[row] + parameters.Mu)
+			if math.Abs(residual) <= epsilon {
+				continue
+			}
+			sign := math.Copysign(1, residual)
+			gradient.Mu -= sign
+			gradient.Beta -= sign * data.X[row]
+		}
+		n := float64(len(rows))
+		return Parameters{gradient.Mu / n, gradient.Beta / n}
+	}
+}
+
+// Returns a training loss gradient with its mu component held at 0, which trains a model through the origin
+func WithoutIntercept(gradientRows GradientFunc) GradientFunc {
+	return func(parameters Parameters, data data.InputData, rows []int) Parameters {
+		gradient := gradientRows(parameters, data, rows)
+		gradient.Mu = 0
+		return gradient
+	}
+}
+
+// Returns the minimum-norm subgradient of the loss whose gradient is given plus lambda * |beta|, the penalty coordinate
+// descent's lambda adds to the mean squared error: a nonzero beta adds lambda times its sign, and a beta at 0 shrinks
+// its gradient towards 0 by lambda. Its norm is 0 at the lasso optimum, where the plain gradient's is not
+func L1Subgradient(gradient Parameters, parameters Parameters, lambda float64) Parameters {
+	switch {
+	case parameters.Beta > 0:
+		gradient.Beta += lambda
+	case parameters.Beta < 0:
+		gradient.Beta -= lambda
+	default:
+		gradient.Beta = math.Copysign(math.Max(math.Abs(gradient.Beta) - lambda, 0), gradient.Beta)
+	}
+	return gradient
+}
+
+// Projects parameters onto the non-negative orthant after an update: a negative beta, and a negative mu if includeMu,
+// is clamped to 0
+func ProjectNonNegative(parameters Parameters, includeMu bool) Parameters {
+	parameters.Beta = math.Max(parameters.Beta, 0)
+	if includeMu {
+		parameters.Mu = math.Max(parameters.Mu, 0)
+	}
+	return parameters
+}
+
+// Returns the gradient at parameters projected by ProjectNonNegative without the components that push a parameter
+// clamped at 0 further below it, which the constraint cancels. Its norm is 0 at the constrained optimum
+func ProjectedGradient(gradient Parameters, parameters Parameters, includeMu bool) Parameters {
+	if parameters.Beta <= 0 && gradient.Beta > 0 {
+		gradient.Beta = 0
+	}
+	if includeMu && parameters.Mu <= 0 && gradient.Mu > 0 {
+		gradient.Mu = 0
+	}
+	return gradient
+}
@@ -0,0 +1,38 @@
+package regression
+
+import (
+	"errors"
+	"sync"
+)
+
+// An evaluation metric of predictions against the actual values, which a search minimizes. Metrics where higher is
+// better, eg R squared, are registered negated
+type Metric func(predicted []float64, actual []float64) float64
+
+// Metrics registered by name, starting with the MSE every search minimizes by default
+var registeredMetrics = struct {
+	sync.RWMutex
+	metrics map[string]Metric
+}{metrics: map[string]Metric{"mse": CalcMSE}}
+
+// Registers a metric under a name, so a search can select it as its objective. A name can only be registered once
+func RegisterMetric(name string, metric Metric) error {
+	if name == "" || metric == nil {
+		return errors.New("regression: a metric needs a name and an implementation")
+	}
+	registeredMetrics.Lock()
+	defer registeredMetrics.Unlock()
+	if _, ok := registeredMetrics.metrics[name]; ok {
+		return errors.New("regression: metric " + name + " is already registered")
+	}
+	registeredMetrics.metrics[name] = metric
+	return nil
+}
+
+// Returns the metric registered under a name, and false if there is none
+func LookupMetric(name string) (Metric, bool) {
+	registeredMetrics.RLock()
+	defer registeredMetrics.RUnlock()
+	metric, ok := registeredMetrics.metrics[name]
+	return metric, ok
+}
@@ -0,0 +1,204 @@
+package regression
+
+import "proj3/data"
+
+// Updates parameters per descent with Nesterov accelerated gradient. The gradient is evaluated at the look ahead point
+// parameters + momentum * velocity, which damps the oscillation plain momentum shows on ill-conditioned problems.
+// Returns the updated parameters and velocity
+func UpdateParamsNesterov(parameters Parameters, velocity Parameters, data data.InputData, alpha float64, momentum float64) (Parameters, Parameters) {
+	return nesterovStep(parameters, velocity, func(lookAhead Parameters) Parameters { return Gradient(lookAhead, data) }, alpha, momentum)
+}
+
+// Updates parameters per mini-batch with Nesterov accelerated gradient, the gradient of a training loss being taken
+// over the given rows only
+func UpdateParamsNesterovRows(parameters Parameters, velocity Parameters, data data.InputData, rows []int, alpha float64, momentum float64,
+	gradientRows GradientFunc) (Parameters, Parameters) {
+	return nesterovStep(parameters, velocity, func(lookAhead Parameters) Parameters { return gradientRows(lookAhead, data, rows) }, alpha, momentum)
+}
+
+// One Nesterov step given the function computing the gradient at the look ahead point
+func nesterovStep(parameters Parameters, velocity Parameters, gradientAt func(Parameters) Parameters, alpha float64, momentum float64) (Parameters, Parameters) {
+	lookAhead := Parameters{parameters.Mu + momentum * velocity.Mu, parameters.Beta + momentum * velocity.Beta}
+	gradient := gradientAt(lookAhead)
+	velocity.Mu = momentum * velocity.Mu - alpha * gradient.Mu
+	velocity.Beta = momentum * velocity.Beta - alpha * gradient.Beta
+	parameters.Mu += velocity.Mu
+	parameters.Beta += velocity.Beta
+	return parameters, velocity
+}
+
+// Constants of the backtracking line search: the step shrinks by lineSearchShrink until the loss decreases by at least
+// lineSearchArmijo times the decrease predicted by the gradient (the Armijo condition), for at most lineSearchMaxSteps tries
+const (
+	lineSearchShrink = 0.5
+	lineSearchArmijo = 1e-4
+	lineSearchMaxSteps = 50
+)
+
+// Updates parameters per descent with a step chosen by backtracking line search, starting from initialStep. Returns
+// the updated parameters and the step taken, which is 0 if no step decreased the loss (eg at the optimum)
+func UpdateParamsLineSearch(parameters Parameters, data data.InputData, initialStep float64) (Parameters, float64) {
+	gradient := Gradient(parameters, data)
+	return backtrack(parameters, Parameters{-gradient.Mu, -gradient.Beta}, gradient, data, initialStep)
+}
+
+// Moves parameters along a descent direction by the largest step, halving from initialStep, that satisfies the Armijo
+// condition. Returns the moved parameters and the step, or the parameters unchanged and 0 if no step qualified
+func backtrack(parameters Parameters, direction Parameters, gradient Parameters, data data.InputData, initialStep float64) (Parameters, float64) {
+	loss := CalcMSE(Forecast(parameters.Mu, parameters.Beta, data.X), data.Y)
+	slope := dotParameters(gradient, direction)
+	step := initialStep
+	for i := 0; i < lineSearchMaxSteps; i++ {
+		candidate := Parameters{parameters.Mu + step * direction.Mu, parameters.Beta + step * direction.Beta}
+		candidateLoss := CalcMSE(Forecast(candidate.Mu, candidate.Beta, data.X), data.Y)
+		if candidateLoss <= loss + lineSearchArmijo * step * slope {
+			return candidate, step
+		}
+		step *= lineSearchShrink
+	}
+	return parameters, 0
+}
+
+// Updates parameters per sweep of coordinate descent: mu and then beta are set to the exact minimizers of
+// MSE + lambda*|beta| with the other held fixed. Beta's minimizer is the soft thresholded least squares slope, which
+// is exactly 0 when lambda outweighs the correlation of x with the residuals
+func UpdateParamsCoordinate(parameters Parameters, data data.InputData, lambda float64) Parameters {
+	n := float64(len(data.X))
+	residualMean := float64(0)
+	for i := 0; i < len(data.X); i++ {
+		residualMean += data.Y[i] - parameters.Beta * data.X[i]
+	}
+	parameters.Mu = residualMean / n
+	rho, squaredX := float64(0), float64(0)
+	for i := 0; i < len(data.X); i++ {
+		rho += data.X[i] * (data.Y[i] - parameters.Mu)
+		squaredX += data.X[i] * data.X[i]
+	}
+	if squaredX == 0 { //x is all zeros, beta has no effect on the loss
+		return parameters
+	}
+	parameters.Beta = softThreshold(rho / n, lambda / 2) / (squaredX / n)
+	return parameters
+}
+
+// Shrinks value towards 0 by threshold, clamping at 0
+func softThreshold(value float64, threshold float64) float64 {
+	if value > threshold {
+		return value - threshold
+	}
+	if value < -threshold {
+		return value + threshold
+	}
+	return 0
+}
+
+// Updates parameters per iteration of linear conjugate gradient on the least squares objective, whose Hessian is
+// applied to each search direction straight from the data. Takes and returns the residual (negative gradient) and
+// search direction carried between iterations; a zero direction restarts from steepest descent. With two parameters
+// the exact least squares fit is reached after 2 iterations, after which the parameters stay put
+func UpdateParamsConjugateGradient(parameters Parameters, residual Parameters, direction Parameters, data data.InputData) (Parameters, Parameters, Parameters) {
+	if direction.Mu == 0 && direction.Beta == 0 {
+		gradient := Gradient(parameters, data)
+		residual = Parameters{-gradient.Mu, -gradient.Beta}
+		direction = residual
+	}
+	curvedDirection := hessianProduct(direction, data)
+	curvature := dotParameters(direction, curvedDirection)
+	if curvature <= 0 { //converged, or x is constant and beta has no curvature
+		return parameters, residual, Parameters{}
+	}
+	residualNormSquared := dotParameters(residual, residual)
+	step := residualNormSquared / curvature
+	parameters.Mu += step * direction.Mu
+	parameters.Beta += step * direction.Beta
+	residual.Mu -= step * curvedDirection.Mu
+	residual.Beta -= step * curvedDirection.Beta
+	conjugacy := dotParameters(residual, residual) / residualNormSquared
+	direction = Parameters{residual.Mu + conjugacy * direction.Mu, residual.Beta + conjugacy * direction.Beta}
+	return parameters, residual, direction
+}
+
+// Multiplies a direction by the Hessian of the MSE, which is (2/n) * [[n, sum(x)], [sum(x), sum(x^2)]]
+func hessianProduct(direction Parameters, data data.InputData) Parameters {
+	product := Parameters{0, 0}
+	for i := 0; i < len(data.X); i++ {
+		change := direction.Mu + direction.Beta * data.X[i]
+		product.Mu += change
+		product.Beta += change * data.X[i]
+	}
+	n := float64(len(data.X))
+	return Parameters{2 * product.Mu / n, 2 * product.Beta / n}
+}
+
+// Returns the dot product of two parameter vectors
+func dotParameters(a Parameters, b Parameters) float64 {
+	return a.Mu * b.Mu + a.Beta * b.Beta
+}
+
+// Curvature pairs remembered by L-BFGS: the most recent parameter steps and the gradient changes they caused, oldest
+// first, capped at size pairs
+type LBFGSMemory struct {
+	size int
+	steps []Parameters
+	gradientChanges []Parameters
+}
+
+// Creates an empty L-BFGS memory holding at most size curvature pairs
+func NewLBFGSMemory(size int) LBFGSMemory {
+	if size < 1 {
+		size = 1
+	}
+	return LBFGSMemory{size: size}
+}
+
+// Updates parameters per iteration of L-BFGS: the gradient is scaled by the inverse Hessian estimate built from the
+// remembered curvature pairs (the two loop recursion), then a backtracking line search from a unit step moves along
+// it. Returns the updated parameters and memory
+func UpdateParamsLBFGS(parameters Parameters, memory LBFGSMemory, data data.InputData) (Parameters, LBFGSMemory) {
+	gradient := Gradient(parameters, data)
+	direction := lbfgsDirection(gradient, memory)
+	if dotParameters(gradient, direction) >= 0 { //estimate stopped pointing downhill, forget it and fall back to steepest descent
+		memory = NewLBFGSMemory(memory.size)
+		direction = Parameters{-gradient.Mu, -gradient.Beta}
+	}
+	updated, step := backtrack(parameters, direction, gradient, data, 1)
+	if step == 0 {
+		return parameters, memory
+	}
+	newGradient := Gradient(updated, data)
+	parameterStep := Parameters{updated.Mu - parameters.Mu, updated.Beta - parameters.Beta}
+	gradientChange := Parameters{newGradient.Mu - gradient.Mu, newGradient.Beta - gradient.Beta}
+	if dotParameters(parameterStep, gradientChange) > 1e-12 { //only pairs with positive curvature keep the estimate positive definite
+		memory.steps = append(memory.steps, parameterStep)
+		memory.gradientChanges = append(memory.gradientChanges, gradientChange)
+		if len(memory.steps) > memory.size {
+			memory.steps = memory.steps[1:]
+			memory.gradientChanges = memory.gradientChanges[1:]
+		}
+	}
+	return updated, memory
+}
+
+// Returns the L-BFGS search direction, the negative gradient multiplied by the inverse Hessian estimate
+func lbfgsDirection(gradient Parameters, memory LBFGSMemory) Parameters {
+	pairs := len(memory.steps)
+	q := gradient
+	weights := make([]float64, pairs)
+	for i := pairs - 1; i >= 0; i-- {
+		weights[i] = dotParameters(memory.steps[i], q) / dotParameters(memory.gradientChanges[i], memory.steps[i])
+		q.Mu -= weights[i] * memory.gradientChanges[i].Mu
+		q.Beta -= weights[i] * memory.gradientChanges[i].Beta
+	}
+	if pairs > 0 { //scale by the newest pair's curvature as the initial inverse Hessian
+		newest := pairs - 1
+		scale := dotParameters(memory.steps[newest], memory.gradientChanges[newest]) / dotParameters(memory.gradientChanges[newest], memory.gradientChanges[newest])
+		q.Mu *= scale
+		q.Beta *= scale
+	}
+	for i := 0; i < pairs; i++ {
+		correction := weights[i] - dotParameters(memory.gradientChanges[i], q) / dotParameters(memory.gradientChanges[i], memory.steps[i])
+		q.Mu += correction * memory.steps[i].Mu
+		q.Beta += correction * memory.steps[i].Beta
+	}
+	return Parameters{-q.Mu, -q.Beta}
+}
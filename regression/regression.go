@@ -3,6 +3,7 @@ package regression
 import (
 	"math"
 	"proj3/data"
+	"sort"
 )
 
 // Calculates MSE, our loss function
@@ -43,6 +44,35 @@ func calcGradientMu(predicted []float64, actual []float64) float64{
 	return gradientMu
 }
 
+// Calculates the gradient of the cost function at the given parameters, with the partial derivatives stored in the
+// matching fields of Parameters
+func Gradient(parameters Parameters, data data.InputData) Parameters {
+	predicted := Forecast(parameters.Mu, parameters.Beta, data.X)
+	return Parameters{calcGradientMu(predicted, data.Y), calcGradientBeta(predicted, data)}
+}
+
+// Calculates the gradient of the cost function over the given rows only, eg a mini-batch. Rows index into data, so a
+// batch never copies the data
+func GradientRows(parameters Parameters, data data.InputData, rows []int) Parameters {
+	gradient := Parameters{0, 0}
+	for _, row := range rows {
+		residual := data.Y[row] - (parameters.Beta * data.X[row] + parameters.Mu)
+		gradient.Mu += residual
+		gradient.Beta += residual * data.X[row]
+	}
+	n := float64(len(rows))
+	return Parameters{-2 * gradient.Mu / n, -2 * gradient.Beta / n}
+}
+
+// Updates parameters per mini-batch descent over the given rows, following the gradient of a training loss, eg
+// GradientRows for the squared loss
+func UpdateParamsRows(parameters Parameters, data data.InputData, rows []int, alpha float64, gradientRows GradientFunc) Parameters {
+	gradient := gradientRows(parameters, data, rows)
+	parameters.Mu -= alpha * gradient.Mu
+	parameters.Beta -= alpha * gradient.Beta
+	return parameters
+}
+
 // Updates parameters per descent. Important that both parameters are updated simultaneously (ie do not update predicted until all parameters are updated)
 func UpdateParams(parameters Parameters, data data.InputData, alpha float64) Parameters {
 	predicted := Forecast(parameters.Mu, parameters.Beta, data.X)
@@ -56,33 +86,76 @@ func Normalize(rawData data.InputData, minX float64, maxX float64) data.InputDat
 	var dataNormalized data.InputData
 	dataNormalized.X = make([]float64, 0)
 	dataNormalized.Y = rawData.Y
+	xRange := normalizationRange(minX, maxX)
 	for i:=0; i < len(rawData.X); i++{
-		dataNormalized.X = append(dataNormalized.X, (rawData.X[i] - minX)/ (maxX - minX))
+		dataNormalized.X = append(dataNormalized.X, (rawData.X[i] - minX)/ xRange)
 	}
 	return dataNormalized
 }
 
 // Denormalizes our parameters, which are calibrated on normalized data
 func UnNormalize (parameters Parameters, data data.InputData, minX float64, maxX float64) Parameters {
-	//in order to grab our correct beta on unnormalized data, we need to unnormalize beta
-	parameters.Beta = parameters.Beta /(maxX - minX) - minX
-	return parameters
+	//beta*(x - minX)/range + mu is beta/range*x + mu - beta/range*minX on unnormalized data
+	return UnScale(parameters, Scaling{X: ColumnScale{minX, normalizationRange(minX, maxX)}})
+}
+
+// Returns the range x is divided by when normalizing. A constant x (maxX == minX, eg a single integer level) would
+// divide by zero, so it is only shifted: every normalized x is then 0, which leaves beta at its initial value and
+// fits mu to the mean of y
+func normalizationRange(minX float64, maxX float64) float64 {
+	if maxX == minX {
+		return 1
+	}
+	return maxX - minX
 }
 
-// Calculates the min and max of a slice
-// This function is from StackExchange: https://stackoverflow.com/questions/34259800/is-there-a-built-in-min-function-for-a-slice-of-int-arguments-or-a-variable-numb
+// Calculates the min and max of a slice, both NaN for an empty slice or one holding a NaN rather than a panic or an
+// order that depends on where the NaN is. Summarize tells those apart
 func MinMax (arr []float64) (float64, float64) {
-	var max float64 = arr[0]
-	var min float64 = arr[0]
+	summary, err := Summarize(arr)
+	if err != nil {
+		return math.NaN(), math.NaN()
+	}
+	return summary.Min, summary.Max
+}
+
+// Calculates the mean of a slice
+func Mean(arr []float64) float64 {
+	sum := float64(0)
+	for _, value := range arr {
+		sum += value
+	}
+	return sum / float64(len(arr))
+}
+
+// Calculates the sample standard deviation of a slice
+func StdDev(arr []float64) float64 {
+	mean := Mean(arr)
+	sumSquares := float64(0)
 	for _, value := range arr {
-		if max < value {
-			max = value
-		}
-		if min > value {
-			min = value
-		}
+		sumSquares += math.Pow(value - mean, 2)
+	}
+	return math.Sqrt(sumSquares / float64(len(arr) - 1))
+}
+
+// Calculates the q quantile (0 <= q <= 1) of a slice by linear interpolation between order statistics, leaving the
+// slice unchanged
+func Quantile(arr []float64, q float64) float64 {
+	sorted := append([]float64(nil), arr...)
+	sort.Float64s(sorted)
+	return sortedQuantile(sorted, q)
+}
+
+// Calculates the Pearson correlation between two slices of equal length
+func Correlation(x []float64, y []float64) float64 {
+	meanX, meanY := Mean(x), Mean(y)
+	covariance, varianceX, varianceY := float64(0), float64(0), float64(0)
+	for i := 0; i < len(x); i++ {
+		covariance += (x[i] - meanX) * (y[i] - meanY)
+		varianceX += math.Pow(x[i] - meanX, 2)
+		varianceY += math.Pow(y[i] - meanY, 2)
 	}
-	return min, max
+	return covariance / math.Sqrt(varianceX * varianceY)
 }
 
 // Member variables represent the intercept and coefficient of our univariate regression model
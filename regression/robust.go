@@ -0,0 +1,114 @@
+package regression
+
+import (
+	"math"
+	"math/rand"
+	"proj3/data"
+	"sort"
+)
+
+// Fits the exact least squares line through the given rows. Returns a flat line at the mean of y when x is constant
+// over the rows
+func LeastSquaresRows(data data.InputData, rows []int) Parameters {
+	meanX, meanY := float64(0), float64(0)
+	for _, row := range rows {
+		meanX += data.X[row]
+		meanY += data.Y[row]
+	}
+	n := float64(len(rows))
+	meanX, meanY = meanX / n, meanY / n
+	covariance, varianceX := float64(0), float64(0)
+	for _, row := range rows {
+		covariance += (data.X[row] - meanX) * (data.Y[row] - meanY)
+		varianceX += (data.X[row] - meanX) * (data.X[row] - meanX)
+	}
+	if varianceX == 0 {
+		return Parameters{meanY, 0}
+	}
+	beta := covariance / varianceX
+	return Parameters{meanY - beta * meanX, beta}
+}
+
+// Fits a line robust to gross outliers by random sample consensus: each iteration draws the line through two random
+// rows and counts its inliers, the rows whose absolute residual is at most threshold. The line with the most inliers
+// wins, and is refit by least squares on its inliers only
+func RANSAC(data data.InputData, iterations int, threshold float64, rng *rand.Rand) Parameters {
+	n := len(data.X)
+	if n < 2 {
+		return LeastSquaresRows(data, []int{0})
+	}
+	bestInliers := -1
+	best := Parameters{0, 0}
+	for i := 0; i < iterations; i++ {
+		first, second := rng.Intn(n), rng.Intn(n - 1)
+		if second >= first { //draw two distinct rows
+			second++
+		}
+		candidate := LeastSquaresRows(data, []int{first, second})
+		inliers := 0
+		for row := 0; row < n; row++ {
+			if math.Abs(data.Y[row] - (candidate.Beta * data.X[row] + candidate.Mu)) <= threshold {
+				inliers++
+			}
+		}
+		if inliers > bestInliers {
+			bestInliers, best = inliers, candidate
+		}
+	}
+	rows := make([]int, 0, bestInliers)
+	for row := 0; row < n; row++ {
+		if math.Abs(data.Y[row] - (best.Beta * data.X[row] + best.Mu)) <= threshold {
+			rows = append(rows, row)
+		}
+	}
+	if len(rows) == 0 { //no iterations were run
+		return best
+	}
+	return LeastSquaresRows(data, rows)
+}
+
+// Theil-Sen estimates from more pairs of rows than this are computed on this many random pairs instead of all of them
+const theilSenMaxPairs = 1000000
+
+// Fits a line by the Theil-Sen estimator: beta is the median slope over pairs of rows and mu the median of y - beta*x,
+// which tolerates up to ~29% of gross outliers without tuning. Large data uses theilSenMaxPairs random pairs drawn
+// from rng rather than all n*(n-1)/2
+func TheilSen(data data.InputData, rng *rand.Rand) Parameters {
+	n := len(data.X)
+	slopes := make([]float64, 0)
+	if n * (n - 1) / 2 <= theilSenMaxPairs {
+		for i := 0; i < n; i++ {
+			for j := i + 1; j < n; j++ {
+				if data.X[i] != data.X[j] {
+					slopes = append(slopes, (data.Y[j] - data.Y[i]) / (data.X[j] - data.X[i]))
+				}
+			}
+		}
+	} else {
+		for k := 0; k < theilSenMaxPairs; k++ {
+			i, j := rng.Intn(n), rng.Intn(n)
+			if data.X[i] != data.X[j] {
+				slopes = append(slopes, (data.Y[j] - data.Y[i]) / (data.X[j] - data.X[i]))
+			}
+		}
+	}
+	if len(slopes) == 0 { //x is constant
+		return Parameters{Median(data.Y), 0}
+	}
+	beta := Median(slopes)
+	intercepts := make([]float64, n)
+	for i := 0; i < n; i++ {
+		intercepts[i] = data.Y[i] - beta * data.X[i]
+	}
+	return Parameters{Median(intercepts), beta}
+}
+
+// Returns the median of arr, reordering arr
+func Median(arr []float64) float64 {
+	sort.Float64s(arr)
+	middle := len(arr) / 2
+	if len(arr) % 2 == 0 {
+		return (arr[middle - 1] + arr[middle]) / 2
+	}
+	return arr[middle]
+}
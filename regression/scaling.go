@@ -0,0 +1,68 @@
+package regression
+
+import "proj3/data"
+
+// Affine scaling (value - Offset) / Scale of one column
+type ColumnScale struct {
+	Offset float64
+	Scale float64
+}
+
+// Scaling of the independent data the model is fit on, x. Feature columns are not scaled, as the fit never reads them
+type Scaling struct {
+	X ColumnScale
+}
+
+// Fits the scaling of x by a method: "minmax" maps it onto [0, 1] and "standard" to mean 0 and standard deviation 1. A
+// constant x is only shifted, as in Normalize. The statistics the loader gathered are used if they still describe the
+// data, saving a pass over x
+func FitScaling(d data.InputData, method string) Scaling {
+	if stats := d.CurrentStats(); stats != nil {
+		return Scaling{scaleFromSummary(stats.X, method)}
+	}
+	return Scaling{fitColumnScale(d.X, method)}
+}
+
+// Fits the scaling of one column
+func fitColumnScale(column []float64, method string) ColumnScale {
+	summary, _ := Summarize(column) //one pass; a column that is empty or holds NaNs is left unscaled
+	return scaleFromSummary(summary, method)
+}
+
+func scaleFromSummary(summary Summary, method string) ColumnScale {
+	if method == "standard" {
+		std := summary.StdDev()
+		if summary.Count < 2 || std == 0 {
+			std = 1
+		}
+		return ColumnScale{summary.Mean, std}
+	}
+	return ColumnScale{summary.Min, normalizationRange(summary.Min, summary.Max)}
+}
+
+// Returns the scaling without its offset, so scaled x is only divided by its scale. A model without an
+// intercept must be fit on uncentered columns, as shifting x would shift the intercept away from 0
+func (s Scaling) Uncentered() Scaling {
+	return Scaling{ColumnScale{0, s.X.Scale}}
+}
+
+// Scales x, leaving y as is. The scaled copy has no feature columns, which training never reads
+func Scale(d data.InputData, scaling Scaling) data.InputData {
+	return data.InputData{X: scaleColumn(d.X, scaling.X), Y: d.Y}
+}
+
+// Returns a scaled copy of a column
+func scaleColumn(column []float64, scale ColumnScale) []float64 {
+	scaled := make([]float64, len(column))
+	for i, value := range column {
+		scaled[i] = (value - scale.Offset) / scale.Scale
+	}
+	return scaled
+}
+
+// Back transforms parameters calibrated on data scaled by scaling to the original scale of x: beta is divided by the
+// scale, and mu absorbs the offset
+func UnScale(parameters Parameters, scaling Scaling) Parameters {
+	beta := parameters.Beta / scaling.X.Scale
+	return Parameters{parameters.Mu - beta * scaling.X.Offset, beta}
+}
@@ -0,0 +1,68 @@
+package regression
+
+import (
+	"errors"
+	"math"
+	"proj3/data"
+	"sort"
+)
+
+// Errors of summarizing a slice that has nothing to summarize, or a NaN that would poison every statistic of it
+var (
+	ErrEmpty = errors.New("regression: no values to summarize")
+	ErrNaN = errors.New("regression: NaN among the values to summarize")
+)
+
+// Count, min, max, mean and variance of a slice in a single pass with Welford's algorithm. It is the accumulator the
+// data loader gathers per column statistics with, so the two agree
+type Summary = data.ColumnStats
+
+// Summarizes a slice in a single pass. An empty slice is ErrEmpty and one holding a NaN is ErrNaN, rather than a
+// panic or statistics that are silently NaN
+func Summarize(arr []float64) (Summary, error) {
+	var s Summary
+	if len(arr) == 0 {
+		return s, ErrEmpty
+	}
+	for _, value := range arr {
+		if math.IsNaN(value) {
+			return Summary{}, ErrNaN
+		}
+		s.Add(value)
+	}
+	return s, nil
+}
+
+// Calculates several quantiles (each 0 <= q <= 1) of a slice with a single sort, by linear interpolation between
+// order statistics as Quantile does, leaving the slice unchanged. An empty slice is ErrEmpty and one holding a NaN,
+// which has no place in the order, is ErrNaN
+func Quantiles(arr []float64, qs ...float64) ([]float64, error) {
+	if len(arr) == 0 {
+		return nil, ErrEmpty
+	}
+	sorted := append([]float64(nil), arr...)
+	for _, value := range sorted {
+		if math.IsNaN(value) {
+			return nil, ErrNaN
+		}
+	}
+	sort.Float64s(sorted)
+	quantiles := make([]float64, len(qs))
+	for i, q := range qs {
+		if q < 0 || q > 1 {
+			return nil, errors.New("regression: quantiles lie between 0 and 1")
+		}
+		quantiles[i] = sortedQuantile(sorted, q)
+	}
+	return quantiles, nil
+}
+
+// Interpolates the q quantile of sorted values
+func sortedQuantile(sorted []float64, q float64) float64 {
+	position := q * float64(len(sorted) - 1)
+	lower := int(math.Floor(position))
+	if lower >= len(sorted) - 1 {
+		return sorted[len(sorted) - 1]
+	}
+	return sorted[lower] + (position - float64(lower)) * (sorted[lower + 1] - sorted[lower])
+}
@@ -0,0 +1,51 @@
+package regression
+
+import (
+	"math"
+	"proj3/data"
+)
+
+// Transformation of a strictly positive, skewed y which training fits in place of y, given by the transformation and
+// its inverse, which maps forecasts of the transformed y back to the scale of y
+type TargetTransform struct {
+	Apply func(y float64) float64
+	Inverse func(z float64) float64
+}
+
+// Returns the Box-Cox transformation (y^lambda - 1) / lambda, which is the log at lambda = 0. Transformed forecasts
+// below -1/lambda are outside its range and map back to 0
+func BoxCox(lambda float64) TargetTransform {
+	if lambda == 0 {
+		return TargetTransform{Apply: math.Log, Inverse: math.Exp}
+	}
+	return TargetTransform{
+		Apply: func(y float64) float64 { return (math.Pow(y, lambda) - 1) / lambda },
+		Inverse: func(z float64) float64 {
+			base := lambda * z + 1
+			if base <= 0 {
+				return 0
+			}
+			return math.Pow(base, 1 / lambda)
+		},
+	}
+}
+
+// Returns a copy of the data with y transformed
+func TransformTarget(d data.InputData, transform TargetTransform) data.InputData {
+	transformed := d
+	transformed.Y = make([]float64, len(d.Y))
+	transformed.Stats = nil //its y statistics are of the untransformed y
+	for i, y := range d.Y {
+		transformed.Y[i] = transform.Apply(y)
+	}
+	return transformed
+}
+
+// Maps forecasts of the transformed y back to the scale of y in place. This gives the median of y rather than its mean
+// when the transformed errors are symmetric
+func InverseTransform(forecasts []float64, transform TargetTransform) []float64 {
+	for i, z := range forecasts {
+		forecasts[i] = transform.Inverse(z)
+	}
+	return forecasts
+}
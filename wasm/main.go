@@ -0,0 +1,66 @@
+//go:build js && wasm
+
+// The search engine compiled to WebAssembly, for a browser based teaching demo. Build it and serve this directory
+// with the runtime support of the Go toolchain:
+//
+//	GOOS=js GOARCH=wasm go build -o wasm/calibrate.wasm ./wasm
+//	cp "$(go env GOROOT)/lib/wasm/wasm_exec.js" wasm/
+//
+// index.html then loads calibrate.wasm, which registers a global
+//
+//	calibrate(x, y, grid, numThreads) -> string
+//
+// taking x and y as arrays or Float64Arrays of numbers and grid as a JSON gridsearch.Grid, and returning the JSON
+// gridsearch.Result of the winner or {"error": "..."}. A browser runs the goroutines of numThreads on one thread, so
+// it only changes how the permutations are sliced
+package main
+
+import (
+	"encoding/json"
+	"proj3/data"
+	"proj3/gridsearch"
+	"syscall/js"
+)
+
+func main() {
+	js.Global().Set("calibrate", js.FuncOf(calibrate))
+	select {} //keep the exported function alive for the page's lifetime
+}
+
+func calibrate(this js.Value, args []js.Value) interface{} {
+	if len(args) < 3 {
+		return encodeError("calibrate(x, y, grid, numThreads) takes x, y and a JSON grid")
+	}
+	numThreads := 1
+	if len(args) > 3 && args[3].Type() == js.TypeNumber {
+		numThreads = args[3].Int()
+	}
+	var grid gridsearch.Grid
+	if err := json.Unmarshal([]byte(args[2].String()), &grid); err != nil {
+		return encodeError(err.Error())
+	}
+	d := data.InputData{X: floats(args[0]), Y: floats(args[1])}
+	result, err := gridsearch.Search(d, grid, numThreads)
+	if err != nil {
+		return encodeError(err.Error())
+	}
+	encoded, err := json.Marshal(result)
+	if err != nil {
+		return encodeError(err.Error())
+	}
+	return string(encoded)
+}
+
+// Copies a JavaScript array or typed array of numbers
+func floats(array js.Value) []float64 {
+	values := make([]float64, array.Length())
+	for i := range values {
+		values[i] = array.Index(i).Float()
+	}
+	return values
+}
+
+func encodeError(message string) string {
+	encoded, _ := json.Marshal(map[string]string{"error": message})
+	return string(encoded)
+}